@@ -8,7 +8,10 @@ import (
 	"net/http"
 	"slices"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
 )
 
 const MAX_RETRIES int = 10
@@ -16,6 +19,8 @@ const MAX_RETRIES int = 10
 type BetterStackClient struct {
 	apiKey  string
 	baseUrl string
+	// contact to label incident interactions with, when no other contact is known
+	defaultContactEmail string
 }
 
 type BetterStackIncidentWebhookPayload struct {
@@ -29,13 +34,18 @@ type BetterStackIncidentWebhookPayload struct {
 	} `json:"data"`
 }
 
-func NewBetterStackClient(apiKey, baseUrl string) *BetterStackClient {
+func NewBetterStackClient(apiKey, baseUrl, defaultContactEmail string) *BetterStackClient {
 	return &BetterStackClient{
-		apiKey:  apiKey,
-		baseUrl: baseUrl,
+		apiKey:              apiKey,
+		baseUrl:             baseUrl,
+		defaultContactEmail: defaultContactEmail,
 	}
 }
 
+func (b *BetterStackClient) Name() string {
+	return "betterstack"
+}
+
 func (b *BetterStackClient) NewRequest(httpMethod, endpoint string, data io.Reader) (*http.Request, error) {
 	req, err := http.NewRequest(httpMethod, b.baseUrl+endpoint, data)
 	if err != nil {
@@ -88,7 +98,7 @@ func (b *BetterStackClient) Do(req *http.Request, expected_status_codes []int) (
 	return res, nil
 }
 
-func (b *BetterStackClient) CreateIncident(escalation_policy, contact_email, incidentName, incidentCause string) (string, error) {
+func (b *BetterStackClient) CreateIncident(incident incidents.Incident) (string, error) {
 	var betterStackIncident struct {
 		RequesterEmail     string `json:"requester_email"`
 		IncidentName       string `json:"name"`
@@ -100,13 +110,22 @@ func (b *BetterStackClient) CreateIncident(escalation_policy, contact_email, inc
 		PushOnCall         bool   `json:"push"`
 		TeamWaitTime       *int   `json:"team_wait,omitempty"`
 		EscalationPolicyId string `json:"policy_id"`
+		TeamName           string `json:"team_name,omitempty"`
 	}
 
-	betterStackIncident.RequesterEmail = contact_email
-	betterStackIncident.IncidentName = incidentName
-	betterStackIncident.Summary = incidentCause
-	betterStackIncident.Description = incidentCause
-	betterStackIncident.EscalationPolicyId = escalation_policy
+	betterStackIncident.RequesterEmail = b.defaultContactEmail
+	betterStackIncident.IncidentName = incident.Name
+	betterStackIncident.Summary = incident.Summary
+	betterStackIncident.Description = incident.Description
+	betterStackIncident.EscalationPolicyId = incident.PolicyId
+	betterStackIncident.TeamName = incident.Team
+	betterStackIncident.CallOnCall = incident.Call
+	betterStackIncident.SMSOnCall = incident.Sms
+	betterStackIncident.EmailOnCall = incident.Email
+	betterStackIncident.PushOnCall = incident.Push
+	if incident.TeamWait > 0 {
+		betterStackIncident.TeamWaitTime = &incident.TeamWait
+	}
 
 	jsonBody, err := json.Marshal(betterStackIncident)
 	if err != nil {
@@ -115,6 +134,9 @@ func (b *BetterStackClient) CreateIncident(escalation_policy, contact_email, inc
 	jsonBodyReader := bytes.NewReader(jsonBody)
 
 	req, err := b.NewRequest("POST", "/api/v2/incidents", jsonBodyReader)
+	if err != nil {
+		return "", err
+	}
 
 	res, err := b.Do(req, []int{201})
 	if err != nil {
@@ -142,7 +164,7 @@ func (b *BetterStackClient) CreateIncident(escalation_policy, contact_email, inc
 	return incidentResponse.Data.Id, nil
 }
 
-func (b *BetterStackClient) AcknowledgeIncident(contact_email, default_contact_email, incidentId string) error {
+func (b *BetterStackClient) AcknowledgeIncident(ref incidents.IncidentRef, contact_email string) error {
 	// create it
 	var betterStackAck struct {
 		AckedBy string `json:"acknowledged_by,omitempty"`
@@ -151,7 +173,7 @@ func (b *BetterStackClient) AcknowledgeIncident(contact_email, default_contact_e
 	betterStackAck.AckedBy = contact_email
 
 	if contact_email == "" {
-		betterStackAck.AckedBy = default_contact_email
+		betterStackAck.AckedBy = b.defaultContactEmail
 	}
 
 	jsonBody, err := json.Marshal(betterStackAck)
@@ -160,7 +182,10 @@ func (b *BetterStackClient) AcknowledgeIncident(contact_email, default_contact_e
 	}
 	jsonBodyReader := bytes.NewReader(jsonBody)
 
-	req, err := b.NewRequest("POST", "/api/v2/incidents/"+incidentId+"/acknowledge", jsonBodyReader)
+	req, err := b.NewRequest("POST", "/api/v2/incidents/"+ref.Id+"/acknowledge", jsonBodyReader)
+	if err != nil {
+		return err
+	}
 
 	res, err := b.Do(req, []int{409, 200})
 	if err != nil {
@@ -177,7 +202,7 @@ func (b *BetterStackClient) AcknowledgeIncident(contact_email, default_contact_e
 	return nil
 }
 
-func (b *BetterStackClient) ResolveIncident(contact_email, default_contact_email, incidentId string) error {
+func (b *BetterStackClient) ResolveIncident(ref incidents.IncidentRef, contact_email string) error {
 	// create it
 	var betterStackAck struct {
 		ResolvedBy string `json:"resolved_by"`
@@ -186,7 +211,7 @@ func (b *BetterStackClient) ResolveIncident(contact_email, default_contact_email
 	betterStackAck.ResolvedBy = contact_email
 
 	if contact_email == "" {
-		betterStackAck.ResolvedBy = default_contact_email
+		betterStackAck.ResolvedBy = b.defaultContactEmail
 	}
 
 	jsonBody, err := json.Marshal(betterStackAck)
@@ -195,7 +220,10 @@ func (b *BetterStackClient) ResolveIncident(contact_email, default_contact_email
 	}
 	jsonBodyReader := bytes.NewReader(jsonBody)
 
-	req, err := b.NewRequest("POST", "/api/v2/incidents/"+incidentId+"/resolve", jsonBodyReader)
+	req, err := b.NewRequest("POST", "/api/v2/incidents/"+ref.Id+"/resolve", jsonBodyReader)
+	if err != nil {
+		return err
+	}
 
 	res, err := b.Do(req, []int{409, 200})
 	if err != nil {
@@ -212,24 +240,102 @@ func (b *BetterStackClient) ResolveIncident(contact_email, default_contact_email
 	return nil
 }
 
-func (b *BetterStackClient) CheckIncidentsEndpoint() error {
-	req, err := b.NewRequest("GET", "/api/v2/incidents", nil)
+func (b *BetterStackClient) AddIncidentComment(ref incidents.IncidentRef, content string) error {
+	var betterStackComment struct {
+		Content string `json:"content"`
+	}
 
-	res, err := b.Do(req, []int{200})
+	betterStackComment.Content = content
+
+	jsonBody, err := json.Marshal(betterStackComment)
 	if err != nil {
-		return fmt.Errorf("Failed to request /api/v2/incidents: %s", err.Error())
+		return err
+	}
+	jsonBodyReader := bytes.NewReader(jsonBody)
+
+	req, err := b.NewRequest("POST", "/api/v2/incidents/"+ref.Id+"/comments", jsonBodyReader)
+	if err != nil {
+		return err
+	}
+
+	res, err := b.Do(req, []int{201, 200})
+	if err != nil {
+		return err
 	}
 	defer res.Body.Close()
 
 	// check response
-	if res.StatusCode != 200 {
-		return fmt.Errorf("Response status code for /api/v2/incidents was %d, not 200", res.StatusCode)
+	if res.StatusCode != 201 && res.StatusCode != 200 {
+		return fmt.Errorf("response status code was %d", res.StatusCode)
 	}
+
+	// return success
 	return nil
 }
 
-func (b *BetterStackClient) TestGetIncident() error {
-	req, err := b.NewRequest("GET", "/api/v2/incidents/697108568", nil)
+func (b *BetterStackClient) GetIncidentStatus(ref incidents.IncidentRef) (string, error) {
+	req, err := b.NewRequest("GET", "/api/v2/incidents/"+ref.Id, nil)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := b.Do(req, []int{200})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var incidentResponse struct {
+		Data struct {
+			Attributes struct {
+				Status string `json:"status"`
+			} `json:"attributes"`
+		} `json:"data"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&incidentResponse)
+	if err != nil {
+		return "", err
+	}
+
+	// "Started", "Acknowledged" or "Resolved"
+	switch strings.ToLower(incidentResponse.Data.Attributes.Status) {
+	case "acknowledged":
+		return incidents.STATUS_ACKNOWLEDGED, nil
+	case "resolved":
+		return incidents.STATUS_RESOLVED, nil
+	default:
+		return incidents.STATUS_TRIGGERED, nil
+	}
+}
+
+func (b *BetterStackClient) ParseWebhook(r *http.Request) (incidents.WebhookEvent, error) {
+	var event BetterStackIncidentWebhookPayload
+
+	err := json.NewDecoder(r.Body).Decode(&event)
+	if err != nil {
+		return incidents.WebhookEvent{}, err
+	}
+
+	webhookEvent := incidents.WebhookEvent{
+		IncidentId: event.Data.Id,
+	}
+
+	switch event.Data.Attributes.Status {
+	case "acknowledged":
+		webhookEvent.Status = incidents.STATUS_ACKNOWLEDGED
+	case "resolved":
+		webhookEvent.Status = incidents.STATUS_RESOLVED
+	}
+
+	return webhookEvent, nil
+}
+
+func (b *BetterStackClient) CheckConnection() error {
+	req, err := b.NewRequest("GET", "/api/v2/incidents", nil)
+	if err != nil {
+		return err
+	}
 
 	res, err := b.Do(req, []int{200})
 	if err != nil {
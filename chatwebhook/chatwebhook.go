@@ -0,0 +1,92 @@
+package chatwebhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+)
+
+// Posts incident updates to a chat incoming webhook, like Slack or Mattermost, as {"text": "..."}.
+// Chat messages can't be acknowledged or looked up, so the incident id is the incident name.
+type ChatWebhookClient struct {
+	webhookUrl string
+	httpClient *http.Client
+}
+
+func NewChatWebhookClient(webhookUrl string) *ChatWebhookClient {
+	return &ChatWebhookClient{
+		webhookUrl: webhookUrl,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (c *ChatWebhookClient) Name() string {
+	return "chatwebhook"
+}
+
+func (c *ChatWebhookClient) post(text string) error {
+	jsonBody, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", c.webhookUrl, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("response status code was %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *ChatWebhookClient) CreateIncident(incident incidents.Incident) (string, error) {
+	err := c.post(fmt.Sprintf("PROBLEM %s: %s", incident.Name, incident.Summary))
+	if err != nil {
+		return "", err
+	}
+	return incident.Name, nil
+}
+
+func (c *ChatWebhookClient) AcknowledgeIncident(ref incidents.IncidentRef, contactEmail string) error {
+	if contactEmail == "" {
+		return c.post(fmt.Sprintf("ACKNOWLEDGED %s", ref.Id))
+	}
+	return c.post(fmt.Sprintf("ACKNOWLEDGED %s by %s", ref.Id, contactEmail))
+}
+
+func (c *ChatWebhookClient) ResolveIncident(ref incidents.IncidentRef, contactEmail string) error {
+	return c.post(fmt.Sprintf("RECOVERY %s", ref.Id))
+}
+
+func (c *ChatWebhookClient) AddIncidentComment(ref incidents.IncidentRef, content string) error {
+	return c.post(fmt.Sprintf("%s: %s", ref.Id, content))
+}
+
+func (c *ChatWebhookClient) GetIncidentStatus(ref incidents.IncidentRef) (string, error) {
+	return "", fmt.Errorf("chat webhooks don't keep incident status")
+}
+
+func (c *ChatWebhookClient) ParseWebhook(r *http.Request) (incidents.WebhookEvent, error) {
+	return incidents.WebhookEvent{}, fmt.Errorf("chat webhooks don't send webhooks")
+}
+
+// Posting a message is the only way to check a chat webhook, so it isn't checked
+func (c *ChatWebhookClient) CheckConnection() error {
+	return nil
+}
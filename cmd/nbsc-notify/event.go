@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Value of a Nagios macro from the environment, with environment_macros enabled Nagios
+// exports $HOSTNAME$ as NAGIOS_HOSTNAME. Host problems use the host macro, service problems the service macro.
+func nagiosMacro(isService bool, hostMacro, serviceMacro string) string {
+	if isService && serviceMacro != "" {
+		return os.Getenv("NAGIOS_" + serviceMacro)
+	}
+	if hostMacro == "" {
+		return ""
+	}
+	return os.Getenv("NAGIOS_" + hostMacro)
+}
+
+// Use the value of the flag, or the Nagios macro when the flag wasn't given
+func flagOrMacro(value *string, isService bool, hostMacro, serviceMacro string) {
+	if *value == "" {
+		*value = nagiosMacro(isService, hostMacro, serviceMacro)
+	}
+}
+
+// Split a comma separated list, like $HOSTGROUPNAMES$
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+type eventFlags struct {
+	site               string
+	policyId           string
+	destinations       string
+	notificationType   string
+	hostname           string
+	serviceName        string
+	serviceDisplayName string
+	problemId          string
+	output             string
+	state              string
+	stateType          string
+	interactingUser    string
+	ackComment         string
+	hostGroups         string
+	serviceGroups      string
+	attempt            string
+	maxAttempts        string
+	longOutput         string
+	perfData           string
+	notificationNumber string
+}
+
+// Parse a numeric macro, empty when Nagios didn't set it
+func parseNumber(name, value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, value)
+	}
+	return number, nil
+}
+
+// Build the notification payload from the flags, falling back to the Nagios environment macros
+func buildEvent(f eventFlags) (models.EventItem, error) {
+	flagOrMacro(&f.hostname, false, "HOSTNAME", "")
+	flagOrMacro(&f.serviceName, false, "SERVICEDESC", "")
+
+	isService := f.serviceName != ""
+
+	flagOrMacro(&f.notificationType, isService, "NOTIFICATIONTYPE", "")
+	flagOrMacro(&f.serviceDisplayName, isService, "", "SERVICEDISPLAYNAME")
+	flagOrMacro(&f.problemId, isService, "HOSTPROBLEMID", "SERVICEPROBLEMID")
+	flagOrMacro(&f.output, isService, "HOSTOUTPUT", "SERVICEOUTPUT")
+	flagOrMacro(&f.state, isService, "HOSTSTATE", "SERVICESTATE")
+	flagOrMacro(&f.stateType, isService, "HOSTSTATETYPE", "SERVICESTATETYPE")
+	flagOrMacro(&f.interactingUser, isService, "HOSTACKAUTHOR", "SERVICEACKAUTHOR")
+	flagOrMacro(&f.ackComment, isService, "HOSTACKCOMMENT", "SERVICEACKCOMMENT")
+	flagOrMacro(&f.hostGroups, false, "HOSTGROUPNAMES", "")
+	if isService {
+		flagOrMacro(&f.serviceGroups, isService, "", "SERVICEGROUPNAMES")
+	}
+	flagOrMacro(&f.attempt, isService, "HOSTATTEMPT", "SERVICEATTEMPT")
+	flagOrMacro(&f.maxAttempts, isService, "MAXHOSTATTEMPTS", "MAXSERVICEATTEMPTS")
+	flagOrMacro(&f.longOutput, isService, "LONGHOSTOUTPUT", "LONGSERVICEOUTPUT")
+	flagOrMacro(&f.perfData, isService, "HOSTPERFDATA", "SERVICEPERFDATA")
+	flagOrMacro(&f.notificationNumber, isService, "HOSTNOTIFICATIONNUMBER", "SERVICENOTIFICATIONNUMBER")
+
+	attempt, err := parseNumber("attempt", f.attempt)
+	if err != nil {
+		return models.EventItem{}, err
+	}
+	maxAttempts, err := parseNumber("max attempts", f.maxAttempts)
+	if err != nil {
+		return models.EventItem{}, err
+	}
+	notificationNumber, err := parseNumber("notification number", f.notificationNumber)
+	if err != nil {
+		return models.EventItem{}, err
+	}
+
+	// Nagios escapes the newlines of long output as \n
+	longOutput := strings.ReplaceAll(f.longOutput, `\n`, "\n")
+
+	event := models.EventItem{
+		NagiosSiteName:                  f.site,
+		NagiosProblemId:                 f.problemId,
+		NagiosProblemHostname:           f.hostname,
+		NagiosProblemServiceName:        f.serviceName,
+		NagiosProblemServiceDisplayName: f.serviceDisplayName,
+		NagiosProblemContent:            f.output,
+		NagiosProblemNotificationType:   strings.ToUpper(f.notificationType),
+		NagiosProblemState:              strings.ToUpper(f.state),
+		NagiosProblemStateType:          strings.ToUpper(f.stateType),
+		BetterStackPolicyId:             f.policyId,
+		InteractingUserEmail:            f.interactingUser,
+		NagiosProblemAckComment:         f.ackComment,
+		NagiosProblemAttempt:            attempt,
+		NagiosProblemMaxAttempts:        maxAttempts,
+		NagiosProblemLongContent:        longOutput,
+		NagiosProblemPerfData:           f.perfData,
+		NagiosNotificationNumber:        notificationNumber,
+		IncidentDestinations:            splitList(f.destinations),
+		NagiosHostGroups:                splitList(f.hostGroups),
+		NagiosServiceGroups:             splitList(f.serviceGroups),
+	}
+
+	if event.NagiosSiteName == "" {
+		return event, fmt.Errorf("missing site name, -site or NBSC_SITE")
+	}
+	if event.NagiosProblemHostname == "" {
+		return event, fmt.Errorf("missing host name, -host or NAGIOS_HOSTNAME")
+	}
+	if event.NagiosProblemNotificationType == "" {
+		return event, fmt.Errorf("missing notification type, -type or NAGIOS_NOTIFICATIONTYPE")
+	}
+	if event.NagiosProblemNotificationType == "PROBLEM" && event.NagiosProblemId == "" {
+		return event, fmt.Errorf("missing problem id, -problem-id or NAGIOS_HOSTPROBLEMID/NAGIOS_SERVICEPROBLEMID")
+	}
+
+	return event, nil
+}
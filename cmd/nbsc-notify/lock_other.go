@@ -0,0 +1,13 @@
+//go:build !unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// Without flock concurrent notifications aren't serialized, Nagios runs on unix
+func (s *spool) lock() (*os.File, error) {
+	return os.OpenFile(filepath.Join(s.dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o640)
+}
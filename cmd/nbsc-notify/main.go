@@ -0,0 +1,201 @@
+// nbsc-notify relays a Nagios notification to the connector.
+//
+// Nagios macros are taken from flags, or from the NAGIOS_* environment variables Nagios
+// exports with environment_macros enabled. The connector is configured with flags or NBSC_* variables.
+//
+// With a spool directory, notifications that can't be delivered are written to it and retried
+// by later runs, or by "nbsc-notify flush".
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Exit codes, logged by Nagios for failed notification commands
+const (
+	EXIT_OK = 0
+	// the connector couldn't be reached, or failed to handle the notification
+	EXIT_FAILED = 1
+	// invalid flags or missing macros
+	EXIT_USAGE = 2
+	// the connector rejected the notification as invalid or unauthorized
+	EXIT_REJECTED = 3
+	// the notification couldn't be delivered and was spooled for retry
+	EXIT_SPOOLED = 4
+)
+
+func main() {
+	var f eventFlags
+
+	url := flag.String("url", os.Getenv("NBSC_URL"), "connector notification endpoint, e.g. https://nbsc.acme.com/api/nagios-event (NBSC_URL)")
+	token := flag.String("token", os.Getenv("NBSC_TOKEN"), "bearer token the connector expects, NAGIOS_EVENT_TOKEN of the connector (NBSC_TOKEN)")
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout of the request to the connector")
+	spoolDir := flag.String("spool-dir", os.Getenv("NBSC_SPOOL_DIR"), "directory to keep undelivered notifications in for retry, optional (NBSC_SPOOL_DIR)")
+
+	flag.StringVar(&f.site, "site", os.Getenv("NBSC_SITE"), "Nagios site name (NBSC_SITE)")
+	flag.StringVar(&f.policyId, "policy-id", os.Getenv("NBSC_POLICY_ID"), "policy id, optional with routing rules (NBSC_POLICY_ID)")
+	flag.StringVar(&f.destinations, "destinations", os.Getenv("NBSC_DESTINATIONS"), "comma separated incident destinations, optional (NBSC_DESTINATIONS)")
+
+	flag.StringVar(&f.notificationType, "type", "", "$NOTIFICATIONTYPE$")
+	flag.StringVar(&f.hostname, "host", "", "$HOSTNAME$")
+	flag.StringVar(&f.serviceName, "service", "", "$SERVICEDESC$, empty for host notifications")
+	flag.StringVar(&f.serviceDisplayName, "service-display-name", "", "$SERVICEDISPLAYNAME$")
+	flag.StringVar(&f.problemId, "problem-id", "", "$SERVICEPROBLEMID$ or $HOSTPROBLEMID$")
+	flag.StringVar(&f.output, "output", "", "$SERVICEOUTPUT$ or $HOSTOUTPUT$")
+	flag.StringVar(&f.state, "state", "", "$SERVICESTATE$ or $HOSTSTATE$")
+	flag.StringVar(&f.stateType, "state-type", "", "$SERVICESTATETYPE$ or $HOSTSTATETYPE$")
+	flag.StringVar(&f.interactingUser, "interacting-user", "", "$SERVICEACKAUTHOR$ or $HOSTACKAUTHOR$")
+	flag.StringVar(&f.ackComment, "ack-comment", "", "$SERVICEACKCOMMENT$ or $HOSTACKCOMMENT$")
+	flag.StringVar(&f.hostGroups, "host-groups", "", "$HOSTGROUPNAMES$")
+	flag.StringVar(&f.serviceGroups, "service-groups", "", "$SERVICEGROUPNAMES$")
+	flag.StringVar(&f.attempt, "attempt", "", "$SERVICEATTEMPT$ or $HOSTATTEMPT$")
+	flag.StringVar(&f.maxAttempts, "max-attempts", "", "$MAXSERVICEATTEMPTS$ or $MAXHOSTATTEMPTS$")
+	flag.StringVar(&f.longOutput, "long-output", "", "$LONGSERVICEOUTPUT$ or $LONGHOSTOUTPUT$")
+	flag.StringVar(&f.perfData, "perfdata", "", "$SERVICEPERFDATA$ or $HOSTPERFDATA$")
+	flag.StringVar(&f.notificationNumber, "notification-number", "", "$SERVICENOTIFICATIONNUMBER$ or $HOSTNOTIFICATIONNUMBER$")
+
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: nbsc-notify [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "       nbsc-notify flush [flags]")
+		fmt.Fprintln(flag.CommandLine.Output(), "Nagios macros not given as flags are read from the NAGIOS_* environment variables.")
+		fmt.Fprintln(flag.CommandLine.Output(), "flush only retries the notifications in the spool directory.")
+		flag.PrintDefaults()
+	}
+
+	args := os.Args[1:]
+	flushOnly := len(args) > 0 && args[0] == "flush"
+	if flushOnly {
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+
+	if *url == "" {
+		fmt.Println("missing connector url, -url or NBSC_URL")
+		os.Exit(EXIT_USAGE)
+	}
+
+	client := newConnectorClient(*url, *token, *timeout)
+
+	if flushOnly {
+		if *spoolDir == "" {
+			fmt.Println("missing spool directory, -spool-dir or NBSC_SPOOL_DIR")
+			os.Exit(EXIT_USAGE)
+		}
+		os.Exit(flush(client, *spoolDir))
+	}
+
+	event, err := buildEvent(f)
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(EXIT_USAGE)
+	}
+
+	if *spoolDir == "" {
+		os.Exit(send(client, event))
+	}
+	os.Exit(sendSpooled(client, *spoolDir, event))
+}
+
+// Send the notification, without a spool to fall back on
+func send(client *connectorClient, event models.EventItem) int {
+	err := client.send(event)
+
+	var rejected *rejectedError
+	switch {
+	case errors.As(err, &rejected):
+		fmt.Println(err.Error())
+		return EXIT_REJECTED
+	case err != nil:
+		fmt.Println("failed to send notification:", err.Error())
+		return EXIT_FAILED
+	}
+
+	fmt.Println("sent", event.NagiosProblemNotificationType, "notification for", event.NagiosProblemHostname, event.NagiosProblemServiceName)
+	return EXIT_OK
+}
+
+// Deliver spooled notifications first, then send the notification, or spool it when it can't be
+// delivered or earlier notifications for its host/service are still spooled
+func sendSpooled(client *connectorClient, spoolDir string, event models.EventItem) int {
+	s, err := newSpool(spoolDir)
+	if err != nil {
+		// sending without the spool could deliver the notification ahead of spooled ones for the same problem
+		fmt.Println("failed to open spool directory:", err.Error())
+		return EXIT_FAILED
+	}
+
+	lock, err := s.lock()
+	if err != nil {
+		fmt.Println("failed to lock spool directory:", err.Error())
+		return EXIT_FAILED
+	}
+	defer lock.Close()
+
+	result, flushErr := s.flush(client)
+	if flushErr != nil {
+		fmt.Println("failed to flush spool directory:", flushErr.Error())
+	}
+	if result.sent > 0 {
+		fmt.Println("sent", result.sent, "spooled notification(s)")
+	}
+
+	// after a failed flush it isn't known which host/services still have notifications spooled, spool behind them
+	if flushErr == nil && !result.unreachable && !result.blocked[problemKey(event)] {
+		err = client.send(event)
+
+		var rejected *rejectedError
+		switch {
+		case errors.As(err, &rejected):
+			fmt.Println(err.Error())
+			return EXIT_REJECTED
+		case err == nil:
+			fmt.Println("sent", event.NagiosProblemNotificationType, "notification for", event.NagiosProblemHostname, event.NagiosProblemServiceName)
+			return EXIT_OK
+		}
+		fmt.Println("failed to send notification:", err.Error())
+	}
+
+	err = s.add(event)
+	if err != nil {
+		fmt.Println("failed to spool notification:", err.Error())
+		return EXIT_FAILED
+	}
+
+	fmt.Println("spooled", event.NagiosProblemNotificationType, "notification for", event.NagiosProblemHostname, event.NagiosProblemServiceName, "for retry")
+	return EXIT_SPOOLED
+}
+
+// Retry the spooled notifications, exits with EXIT_OK once none are left
+func flush(client *connectorClient, spoolDir string) int {
+	s, err := newSpool(spoolDir)
+	if err != nil {
+		fmt.Println("failed to open spool directory:", err.Error())
+		return EXIT_FAILED
+	}
+
+	lock, err := s.lock()
+	if err != nil {
+		fmt.Println("failed to lock spool directory:", err.Error())
+		return EXIT_FAILED
+	}
+	defer lock.Close()
+
+	result, err := s.flush(client)
+	if err != nil {
+		fmt.Println("failed to flush spool directory:", err.Error())
+		return EXIT_FAILED
+	}
+
+	fmt.Println("sent", result.sent, "spooled notification(s),", result.rejected, "rejected")
+	if len(result.blocked) > 0 {
+		fmt.Println("notifications for", len(result.blocked), "host(s)/service(s) left in the spool")
+		return EXIT_FAILED
+	}
+	return EXIT_OK
+}
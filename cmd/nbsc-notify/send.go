@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// The connector refused the notification, sending it again won't help
+type rejectedError struct {
+	statusCode int
+	message    string
+}
+
+func (e *rejectedError) Error() string {
+	return fmt.Sprintf("connector rejected notification: %d %s", e.statusCode, e.message)
+}
+
+type connectorClient struct {
+	url        string
+	token      string
+	httpClient *http.Client
+}
+
+func newConnectorClient(url, token string, timeout time.Duration) *connectorClient {
+	return &connectorClient{
+		url:        url,
+		token:      token,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// Post the notification to the connector. Returns a *rejectedError when the connector refused the
+// notification itself (400, 422). Other responses, e.g. a wrong token (401, 403), a proxy without the
+// connector behind it (404) or throttling (408, 429), may succeed later and are returned as errors.
+func (c *connectorClient) send(event models.EventItem) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "nbsc-notify")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnprocessableEntity {
+		return &rejectedError{statusCode: resp.StatusCode, message: strings.TrimSpace(string(message))}
+	}
+
+	return fmt.Errorf("connector failed to handle notification: %d %s", resp.StatusCode, strings.TrimSpace(string(message)))
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Notification that couldn't be delivered, kept in the spool directory until it is
+type spooledEvent struct {
+	// unix timestamp
+	SpooledAt int64            `json:"spooledAt"`
+	Event     models.EventItem `json:"event"`
+}
+
+// Directory of undelivered notifications, one file per notification named so they sort in the order they were spooled.
+// Notifications the connector rejected are moved to the rejected subdirectory.
+type spool struct {
+	dir string
+}
+
+func newSpool(dir string) (*spool, error) {
+	err := os.MkdirAll(filepath.Join(dir, "rejected"), 0o750)
+	if err != nil {
+		return nil, err
+	}
+	return &spool{dir: dir}, nil
+}
+
+// Notifications for the same host/service must be delivered in order, so a PROBLEM never arrives after its RECOVERY
+func problemKey(event models.EventItem) string {
+	return event.NagiosSiteName + "|" + event.NagiosProblemHostname + "|" + event.NagiosProblemServiceName
+}
+
+// Write the notification to its own file, synced to disk before it is visible in the spool
+func (s *spool) add(event models.EventItem) error {
+	data, err := json.Marshal(spooledEvent{SpooledAt: time.Now().Unix(), Event: event})
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%020d-%d.json", time.Now().UnixNano(), os.Getpid())
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	closeErr := tmp.Close()
+	if err != nil {
+		return err
+	}
+	if closeErr != nil {
+		return closeErr
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(s.dir, name))
+	if err != nil {
+		return err
+	}
+
+	return syncDir(s.dir)
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// Spooled notification files, oldest first
+func (s *spool) files() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".json") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+type flushResult struct {
+	sent     int
+	rejected int
+	// problem keys with notifications left in the spool, later notifications for them must be spooled behind them
+	blocked map[string]bool
+	// the connector couldn't be reached, nothing more should be sent this run
+	unreachable bool
+}
+
+func isUnreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Send spooled notifications in order. A notification that fails keeps later notifications for its host/service in the spool.
+// Caller must hold the spool lock.
+func (s *spool) flush(client *connectorClient) (flushResult, error) {
+	result := flushResult{blocked: map[string]bool{}}
+
+	files, err := s.files()
+	if err != nil {
+		return result, err
+	}
+
+	for _, name := range files {
+		path := filepath.Join(s.dir, name)
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return result, err
+		}
+
+		var spooled spooledEvent
+		err = json.Unmarshal(data, &spooled)
+		if err != nil {
+			// unreadable files can't be delivered, keep them out of the way for inspection
+			fmt.Println("moving unreadable spool file", name, "to rejected:", err.Error())
+			os.Rename(path, filepath.Join(s.dir, "rejected", name))
+			result.rejected++
+			continue
+		}
+
+		key := problemKey(spooled.Event)
+		if result.unreachable || result.blocked[key] {
+			result.blocked[key] = true
+			continue
+		}
+
+		err = client.send(spooled.Event)
+
+		var rejected *rejectedError
+		switch {
+		case errors.As(err, &rejected):
+			fmt.Println("moving rejected spool file", name, "to rejected:", err.Error())
+			err = os.Rename(path, filepath.Join(s.dir, "rejected", name))
+			if err != nil {
+				return result, err
+			}
+			result.rejected++
+		case err != nil:
+			fmt.Println("failed to send spooled notification", name+":", err.Error())
+			result.blocked[key] = true
+			result.unreachable = isUnreachable(err)
+		default:
+			err = os.Remove(path)
+			if err != nil {
+				return result, err
+			}
+			result.sent++
+		}
+	}
+
+	if result.sent > 0 || result.rejected > 0 {
+		err = syncDir(s.dir)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Connector test server answering notifications with the status code set for their host, 200 for other hosts.
+// Records the notifications it receives, in order.
+type fakeConnector struct {
+	mutex    sync.Mutex
+	statuses map[string]int
+	received []models.EventItem
+}
+
+func newFakeConnector(t *testing.T) (*fakeConnector, *connectorClient) {
+	t.Helper()
+
+	fake := &fakeConnector{statuses: map[string]int{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event models.EventItem
+		err := json.NewDecoder(r.Body).Decode(&event)
+		if err != nil {
+			t.Errorf("invalid notification: %v", err)
+		}
+
+		fake.mutex.Lock()
+		defer fake.mutex.Unlock()
+		fake.received = append(fake.received, event)
+
+		status, ok := fake.statuses[event.NagiosProblemHostname]
+		if !ok {
+			status = http.StatusOK
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+
+	return fake, newConnectorClient(server.URL, "", 5*time.Second)
+}
+
+func (f *fakeConnector) setStatus(host string, status int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.statuses[host] = status
+}
+
+// Host and notification type of the notifications received, in order
+func (f *fakeConnector) notifications() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	notifications := []string{}
+	for _, event := range f.received {
+		notifications = append(notifications, event.NagiosProblemHostname+" "+event.NagiosProblemNotificationType)
+	}
+	return notifications
+}
+
+func notification(host, notificationType string) models.EventItem {
+	return models.EventItem{
+		NagiosSiteName:                "site-a",
+		NagiosProblemHostname:         host,
+		NagiosProblemNotificationType: notificationType,
+	}
+}
+
+func newTestSpool(t *testing.T, events ...models.EventItem) *spool {
+	t.Helper()
+
+	s, err := newSpool(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, event := range events {
+		err = s.add(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return s
+}
+
+func spooledFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := []string{}
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && filepath.Ext(entry.Name()) == ".json" {
+			files = append(files, entry.Name())
+		}
+	}
+	return files
+}
+
+func equalNotifications(t *testing.T, got []string, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got notifications %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got notifications %q, want %q", got, want)
+		}
+	}
+}
+
+func TestSpoolKeepsOrderPerProblem(t *testing.T) {
+	fake, client := newFakeConnector(t)
+	s := newTestSpool(t,
+		notification("web-1", "PROBLEM"),
+		notification("web-2", "PROBLEM"),
+		notification("web-1", "RECOVERY"),
+	)
+
+	fake.setStatus("web-1", http.StatusInternalServerError)
+	result, err := s.flush(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the RECOVERY of web-1 waits behind its PROBLEM, web-2 is delivered
+	equalNotifications(t, fake.notifications(), "web-1 PROBLEM", "web-2 PROBLEM")
+	if result.sent != 1 || result.unreachable || !result.blocked[problemKey(notification("web-1", ""))] {
+		t.Errorf("unexpected flush result: %+v", result)
+	}
+	if len(spooledFiles(t, s.dir)) != 2 {
+		t.Errorf("expected 2 notifications left in the spool, got %d", len(spooledFiles(t, s.dir)))
+	}
+
+	// a new notification for web-1 is spooled behind the others, one for web-3 is sent
+	if code := sendSpooled(client, s.dir, notification("web-1", "ACKNOWLEDGEMENT")); code != EXIT_SPOOLED {
+		t.Errorf("expected web-1 to be spooled, exit code %d", code)
+	}
+	if code := sendSpooled(client, s.dir, notification("web-3", "PROBLEM")); code != EXIT_OK {
+		t.Errorf("expected web-3 to be sent, exit code %d", code)
+	}
+
+	fake.setStatus("web-1", http.StatusOK)
+	result, err = s.flush(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	equalNotifications(t, fake.notifications()[len(fake.notifications())-3:], "web-1 PROBLEM", "web-1 RECOVERY", "web-1 ACKNOWLEDGEMENT")
+	if result.sent != 3 || len(spooledFiles(t, s.dir)) != 0 {
+		t.Errorf("expected the spool to be empty, flush result: %+v", result)
+	}
+}
+
+func TestSpoolRejectedResponses(t *testing.T) {
+	tests := []struct {
+		status   int
+		rejected bool
+	}{
+		{http.StatusBadRequest, true},
+		{http.StatusUnprocessableEntity, true},
+		{http.StatusUnauthorized, false},
+		{http.StatusForbidden, false},
+		{http.StatusNotFound, false},
+		{http.StatusTooManyRequests, false},
+		{http.StatusServiceUnavailable, false},
+	}
+
+	for _, test := range tests {
+		t.Run(http.StatusText(test.status), func(t *testing.T) {
+			fake, client := newFakeConnector(t)
+			s := newTestSpool(t, notification("web-1", "PROBLEM"), notification("web-1", "RECOVERY"))
+			fake.setStatus("web-1", test.status)
+
+			result, err := s.flush(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rejectedFiles := spooledFiles(t, filepath.Join(s.dir, "rejected"))
+			if test.rejected {
+				// rejected notifications don't block the ones after them
+				if result.rejected != 2 || len(rejectedFiles) != 2 || len(spooledFiles(t, s.dir)) != 0 {
+					t.Errorf("expected both notifications to be rejected, flush result: %+v", result)
+				}
+				return
+			}
+
+			equalNotifications(t, fake.notifications(), "web-1 PROBLEM")
+			if result.rejected != 0 || len(rejectedFiles) != 0 || len(spooledFiles(t, s.dir)) != 2 {
+				t.Errorf("expected both notifications to stay spooled, flush result: %+v", result)
+			}
+		})
+	}
+}
+
+func TestSpoolStopsWhenUnreachable(t *testing.T) {
+	fake, client := newFakeConnector(t)
+	s := newTestSpool(t, notification("web-1", "PROBLEM"), notification("web-2", "PROBLEM"))
+
+	unreachable := newConnectorClient("http://127.0.0.1:1", "", time.Second)
+	result, err := s.flush(unreachable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.unreachable || !result.blocked[problemKey(notification("web-2", ""))] {
+		t.Errorf("expected the run to stop at the first notification, flush result: %+v", result)
+	}
+	if len(spooledFiles(t, s.dir)) != 2 {
+		t.Errorf("expected both notifications to stay spooled, got %d", len(spooledFiles(t, s.dir)))
+	}
+
+	// notifications for other host/services are spooled as well while the connector is unreachable
+	if code := sendSpooled(unreachable, s.dir, notification("web-3", "PROBLEM")); code != EXIT_SPOOLED {
+		t.Errorf("expected web-3 to be spooled, exit code %d", code)
+	}
+	if len(fake.notifications()) != 0 {
+		t.Errorf("unexpected notifications: %q", fake.notifications())
+	}
+
+	result, err = s.flush(client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	equalNotifications(t, fake.notifications(), "web-1 PROBLEM", "web-2 PROBLEM", "web-3 PROBLEM")
+}
+
+func TestSendSpooledAfterFailedFlush(t *testing.T) {
+	fake, client := newFakeConnector(t)
+	s := newTestSpool(t, notification("web-1", "PROBLEM"), notification("web-2", "PROBLEM"))
+	fake.setStatus("web-1", http.StatusBadRequest)
+
+	// moving the rejected notification fails, so the flush stops before web-2
+	files := spooledFiles(t, s.dir)
+	err := os.MkdirAll(filepath.Join(s.dir, "rejected", files[0], "in-the-way"), 0o750)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := sendSpooled(client, s.dir, notification("web-2", "RECOVERY"))
+	if code != EXIT_SPOOLED {
+		t.Errorf("expected the notification to be spooled after a failed flush, exit code %d", code)
+	}
+	equalNotifications(t, fake.notifications(), "web-1 PROBLEM")
+}
@@ -9,10 +9,63 @@ type DatabaseClient interface {
 	// should be safe to call multiple times
 	CreateEventItemTable() error
 	CreateEventItem(item models.EventItem) (int64, error)
+	UpdateEventItem(item models.EventItem) (int64, error)
 	DeleteEventItem(id int64) (int64, error)
 	GetAllEventItems() ([]models.EventItem, error)
+	// should be safe to call multiple times
+	CreateDowntimeItemTable() error
+	CreateDowntimeItem(item models.DowntimeItem) (int64, error)
+	UpdateDowntimeItem(item models.DowntimeItem) (int64, error)
+	DeleteDowntimeItem(id int64) (int64, error)
+	GetAllDowntimeItems() ([]models.DowntimeItem, error)
+	// should be safe to call multiple times
+	CreateFlappingItemTable() error
+	CreateFlappingItem(item models.FlappingItem) (int64, error)
+	DeleteFlappingItem(id int64) (int64, error)
+	GetAllFlappingItems() ([]models.FlappingItem, error)
+	// should be safe to call multiple times
+	CreateDestinationItemTable() error
+	CreateDestinationItem(item models.DestinationItem) (int64, error)
+	UpdateDestinationItem(item models.DestinationItem) (int64, error)
+	DeleteDestinationItem(id int64) (int64, error)
+	GetAllDestinationItems() ([]models.DestinationItem, error)
+	// should be safe to call multiple times
+	CreateCorrelationItemTable() error
+	CreateCorrelationItem(item models.CorrelationItem) (int64, error)
+	DeleteCorrelationItem(id int64) (int64, error)
+	GetAllCorrelationItems() ([]models.CorrelationItem, error)
+	// should be safe to call multiple times
+	CreateGroupMemberItemTable() error
+	CreateGroupMemberItem(item models.GroupMemberItem) (int64, error)
+	DeleteGroupMemberItem(id int64) (int64, error)
+	GetAllGroupMemberItems() ([]models.GroupMemberItem, error)
+	// should be safe to call multiple times
+	CreateSuppressionItemTable() error
+	CreateSuppressionItem(item models.SuppressionItem) (int64, error)
+	UpdateSuppressionItem(item models.SuppressionItem) (int64, error)
+	DeleteSuppressionItem(id int64) (int64, error)
+	GetAllSuppressionItems() ([]models.SuppressionItem, error)
+	// should be safe to call multiple times
+	CreatePendingItemTable() error
+	CreatePendingItem(item models.PendingItem) (int64, error)
+	UpdatePendingItem(item models.PendingItem) (int64, error)
+	DeletePendingItem(id int64) (int64, error)
+	GetAllPendingItems() ([]models.PendingItem, error)
+	// should be safe to call multiple times
+	CreateRecurrenceItemTable() error
+	CreateRecurrenceItem(item models.RecurrenceItem) (int64, error)
+	UpdateRecurrenceItem(item models.RecurrenceItem) (int64, error)
+	DeleteRecurrenceItem(id int64) (int64, error)
+	GetAllRecurrenceItems() ([]models.RecurrenceItem, error)
+	// should be safe to call multiple times
+	CreateDecisionItemTable() error
+	CreateDecisionItem(item models.DecisionItem) (int64, error)
+	GetAllDecisionItems() ([]models.DecisionItem, error)
 	Lock()
 	Unlock()
 	Shutdown() error
-	Backup()
+	// returns the path of the backup
+	Backup() (string, error)
+	// replaces the database with a backup, returns the path of the backup of the replaced database
+	Restore(backupPath string) (string, error)
 }
@@ -0,0 +1,135 @@
+package sqlitedb
+
+import (
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateCorrelationItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS correlations (
+		id INTEGER PRIMARY KEY,
+		parentEventItemId INTEGER,
+		nagiosSiteName TEXT,
+		nagiosProblemId TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		nagiosProblemContent TEXT,
+		betterStackPolicyId TEXT,
+		createdAt INTEGER NOT NULL DEFAULT 0 )`)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateCorrelationItem(item models.CorrelationItem) (int64, error) {
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO correlations (
+		parentEventItemId,
+		nagiosSiteName,
+		nagiosProblemId,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		nagiosProblemContent,
+		betterStackPolicyId,
+		createdAt )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.ParentEventItemId,
+		item.NagiosSiteName,
+		item.NagiosProblemId,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.NagiosProblemContent,
+		item.BetterStackPolicyId,
+		item.CreatedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) DeleteCorrelationItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM correlations WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllCorrelationItems() ([]models.CorrelationItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		parentEventItemId,
+		nagiosSiteName,
+		nagiosProblemId,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		nagiosProblemContent,
+		betterStackPolicyId,
+		createdAt
+	FROM correlations
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.CorrelationItem{}
+	for rows.Next() {
+		var item models.CorrelationItem
+		err := rows.Scan(
+			&item.Id,
+			&item.ParentEventItemId,
+			&item.NagiosSiteName,
+			&item.NagiosProblemId,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.NagiosProblemContent,
+			&item.BetterStackPolicyId,
+			&item.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,127 @@
+package sqlitedb
+
+import (
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateDecisionItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS decisions (
+		id INTEGER PRIMARY KEY,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		nagiosProblemNotificationType TEXT,
+		betterStackIncidentId TEXT,
+		decision TEXT,
+		reason TEXT,
+		createdAt INTEGER NOT NULL DEFAULT 0,
+		suppressionItemId INTEGER NOT NULL DEFAULT 0 )`)
+
+	if err != nil {
+		return err
+	}
+
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("decisions", "suppressionItemId", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateDecisionItem(item models.DecisionItem) (int64, error) {
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO decisions (
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		nagiosProblemNotificationType,
+		betterStackIncidentId,
+		decision,
+		reason,
+		createdAt,
+		suppressionItemId )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.NagiosProblemNotificationType,
+		item.BetterStackIncidentId,
+		item.Decision,
+		item.Reason,
+		item.CreatedAt,
+		item.SuppressionItemId,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) GetAllDecisionItems() ([]models.DecisionItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		nagiosProblemNotificationType,
+		betterStackIncidentId,
+		decision,
+		reason,
+		createdAt,
+		suppressionItemId
+	FROM decisions
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.DecisionItem{}
+	for rows.Next() {
+		var item models.DecisionItem
+		err := rows.Scan(
+			&item.Id,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.NagiosProblemNotificationType,
+			&item.BetterStackIncidentId,
+			&item.Decision,
+			&item.Reason,
+			&item.CreatedAt,
+			&item.SuppressionItemId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
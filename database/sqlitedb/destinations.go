@@ -0,0 +1,156 @@
+package sqlitedb
+
+import (
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateDestinationItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS destinations (
+		id INTEGER PRIMARY KEY,
+		eventItemId INTEGER,
+		destination TEXT,
+		provider TEXT,
+		policyId TEXT,
+		incidentId TEXT,
+		status TEXT )`)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateDestinationItem(item models.DestinationItem) (int64, error) {
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO destinations (
+		eventItemId,
+		destination,
+		provider,
+		policyId,
+		incidentId,
+		status )
+	VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.EventItemId,
+		item.Destination,
+		item.Provider,
+		item.PolicyId,
+		item.IncidentId,
+		item.Status,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) UpdateDestinationItem(item models.DestinationItem) (int64, error) {
+	stmt, err := s.db.Prepare(`
+	UPDATE destinations SET
+		eventItemId = ?,
+		destination = ?,
+		provider = ?,
+		policyId = ?,
+		incidentId = ?,
+		status = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.EventItemId,
+		item.Destination,
+		item.Provider,
+		item.PolicyId,
+		item.IncidentId,
+		item.Status,
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) DeleteDestinationItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM destinations WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllDestinationItems() ([]models.DestinationItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		eventItemId,
+		destination,
+		provider,
+		policyId,
+		incidentId,
+		status
+	FROM destinations
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.DestinationItem{}
+	for rows.Next() {
+		var item models.DestinationItem
+		err := rows.Scan(
+			&item.Id,
+			&item.EventItemId,
+			&item.Destination,
+			&item.Provider,
+			&item.PolicyId,
+			&item.IncidentId,
+			&item.Status,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,198 @@
+package sqlitedb
+
+import (
+	"encoding/json"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateDowntimeItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS downtimes (
+		id INTEGER PRIMARY KEY,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		betterStackPolicyId TEXT,
+		startedAt INTEGER NOT NULL DEFAULT 0,
+		events TEXT NOT NULL DEFAULT '[]' )`)
+
+	if err != nil {
+		return err
+	}
+
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("downtimes", "events", "TEXT NOT NULL DEFAULT '[]'")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateDowntimeItem(item models.DowntimeItem) (int64, error) {
+	events, err := marshalEvents(item.Events)
+	if err != nil {
+		return 0, err
+	}
+
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO downtimes (
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		betterStackPolicyId,
+		startedAt,
+		events )
+	VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.BetterStackPolicyId,
+		item.StartedAt,
+		events,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) UpdateDowntimeItem(item models.DowntimeItem) (int64, error) {
+	events, err := marshalEvents(item.Events)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := s.db.Prepare(`
+	UPDATE downtimes SET
+		nagiosSiteName = ?,
+		nagiosProblemType = ?,
+		nagiosProblemHostname = ?,
+		nagiosProblemServiceName = ?,
+		betterStackPolicyId = ?,
+		startedAt = ?,
+		events = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.BetterStackPolicyId,
+		item.StartedAt,
+		events,
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) DeleteDowntimeItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM downtimes WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllDowntimeItems() ([]models.DowntimeItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		betterStackPolicyId,
+		startedAt,
+		events
+	FROM downtimes
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.DowntimeItem{}
+	for rows.Next() {
+		var item models.DowntimeItem
+		var events string
+		err := rows.Scan(
+			&item.Id,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.BetterStackPolicyId,
+			&item.StartedAt,
+			&events,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(events), &item.Events)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// Events stored as JSON, never null so older rows and new rows read the same
+func marshalEvents(events []models.EventItem) (string, error) {
+	if events == nil {
+		events = []models.EventItem{}
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
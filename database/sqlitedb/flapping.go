@@ -0,0 +1,120 @@
+package sqlitedb
+
+import (
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateFlappingItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS flapping (
+		id INTEGER PRIMARY KEY,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		betterStackPolicyId TEXT,
+		startedAt INTEGER NOT NULL DEFAULT 0 )`)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateFlappingItem(item models.FlappingItem) (int64, error) {
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO flapping (
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		betterStackPolicyId,
+		startedAt )
+	VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.BetterStackPolicyId,
+		item.StartedAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) DeleteFlappingItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM flapping WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllFlappingItems() ([]models.FlappingItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		betterStackPolicyId,
+		startedAt
+	FROM flapping
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.FlappingItem{}
+	for rows.Next() {
+		var item models.FlappingItem
+		err := rows.Scan(
+			&item.Id,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.BetterStackPolicyId,
+			&item.StartedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,137 @@
+package sqlitedb
+
+import (
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateGroupMemberItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS group_members (
+		id INTEGER PRIMARY KEY,
+		eventItemId INTEGER,
+		groupKey TEXT,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		createdAt INTEGER NOT NULL DEFAULT 0,
+		nagiosProblemId TEXT NOT NULL DEFAULT '' )`)
+
+	if err != nil {
+		return err
+	}
+
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("group_members", "nagiosProblemId", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateGroupMemberItem(item models.GroupMemberItem) (int64, error) {
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO group_members (
+		eventItemId,
+		groupKey,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		createdAt,
+		nagiosProblemId )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.EventItemId,
+		item.GroupKey,
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.CreatedAt,
+		item.NagiosProblemId,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) DeleteGroupMemberItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM group_members WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllGroupMemberItems() ([]models.GroupMemberItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		eventItemId,
+		groupKey,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		createdAt,
+		nagiosProblemId
+	FROM group_members
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.GroupMemberItem{}
+	for rows.Next() {
+		var item models.GroupMemberItem
+		err := rows.Scan(
+			&item.Id,
+			&item.EventItemId,
+			&item.GroupKey,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.CreatedAt,
+			&item.NagiosProblemId,
+		)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,205 @@
+package sqlitedb
+
+import (
+	"encoding/json"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreatePendingItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS pending (
+		id INTEGER PRIMARY KEY,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		event TEXT,
+		createdAt INTEGER NOT NULL DEFAULT 0,
+		dueAt INTEGER NOT NULL DEFAULT 0,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		lastError TEXT NOT NULL DEFAULT '' )`)
+
+	if err != nil {
+		return err
+	}
+
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("pending", "attempts", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = s.addColumnIfMissing("pending", "lastError", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreatePendingItem(item models.PendingItem) (int64, error) {
+	event, err := json.Marshal(item.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO pending (
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		event,
+		createdAt,
+		dueAt,
+		attempts,
+		lastError )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		string(event),
+		item.CreatedAt,
+		item.DueAt,
+		item.Attempts,
+		item.LastError,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) UpdatePendingItem(item models.PendingItem) (int64, error) {
+	event, err := json.Marshal(item.Event)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := s.db.Prepare(`
+	UPDATE pending SET
+		nagiosSiteName = ?,
+		nagiosProblemType = ?,
+		nagiosProblemHostname = ?,
+		nagiosProblemServiceName = ?,
+		event = ?,
+		createdAt = ?,
+		dueAt = ?,
+		attempts = ?,
+		lastError = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		string(event),
+		item.CreatedAt,
+		item.DueAt,
+		item.Attempts,
+		item.LastError,
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) DeletePendingItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM pending WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllPendingItems() ([]models.PendingItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		event,
+		createdAt,
+		dueAt,
+		attempts,
+		lastError
+	FROM pending
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.PendingItem{}
+	for rows.Next() {
+		var item models.PendingItem
+		var event string
+		err := rows.Scan(
+			&item.Id,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&event,
+			&item.CreatedAt,
+			&item.DueAt,
+			&item.Attempts,
+			&item.LastError,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(event), &item.Event)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -0,0 +1,195 @@
+package sqlitedb
+
+import (
+	"encoding/json"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateRecurrenceItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS recurrences (
+		id INTEGER PRIMARY KEY,
+		nagiosSiteName TEXT,
+		nagiosProblemType TEXT,
+		nagiosProblemHostname TEXT,
+		nagiosProblemServiceName TEXT,
+		incidents INTEGER NOT NULL DEFAULT 0,
+		recurrences INTEGER NOT NULL DEFAULT 0,
+		lastIncidents TEXT,
+		lastOpenedAt INTEGER NOT NULL DEFAULT 0,
+		lastRecoveredAt INTEGER NOT NULL DEFAULT 0 )`)
+
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateRecurrenceItem(item models.RecurrenceItem) (int64, error) {
+	lastIncidents, err := json.Marshal(item.LastIncidents)
+	if err != nil {
+		return 0, err
+	}
+
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO recurrences (
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		incidents,
+		recurrences,
+		lastIncidents,
+		lastOpenedAt,
+		lastRecoveredAt )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.Incidents,
+		item.Recurrences,
+		string(lastIncidents),
+		item.LastOpenedAt,
+		item.LastRecoveredAt,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) UpdateRecurrenceItem(item models.RecurrenceItem) (int64, error) {
+	lastIncidents, err := json.Marshal(item.LastIncidents)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := s.db.Prepare(`
+	UPDATE recurrences SET
+		nagiosSiteName = ?,
+		nagiosProblemType = ?,
+		nagiosProblemHostname = ?,
+		nagiosProblemServiceName = ?,
+		incidents = ?,
+		recurrences = ?,
+		lastIncidents = ?,
+		lastOpenedAt = ?,
+		lastRecoveredAt = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.Incidents,
+		item.Recurrences,
+		string(lastIncidents),
+		item.LastOpenedAt,
+		item.LastRecoveredAt,
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) DeleteRecurrenceItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM recurrences WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllRecurrenceItems() ([]models.RecurrenceItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		nagiosSiteName,
+		nagiosProblemType,
+		nagiosProblemHostname,
+		nagiosProblemServiceName,
+		incidents,
+		recurrences,
+		lastIncidents,
+		lastOpenedAt,
+		lastRecoveredAt
+	FROM recurrences
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.RecurrenceItem{}
+	for rows.Next() {
+		var item models.RecurrenceItem
+		var lastIncidents string
+		err := rows.Scan(
+			&item.Id,
+			&item.NagiosSiteName,
+			&item.NagiosProblemType,
+			&item.NagiosProblemHostname,
+			&item.NagiosProblemServiceName,
+			&item.Incidents,
+			&item.Recurrences,
+			&lastIncidents,
+			&item.LastOpenedAt,
+			&item.LastRecoveredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(lastIncidents), &item.LastIncidents)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
@@ -2,6 +2,11 @@ package sqlitedb
 
 import (
 	"database/sql"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pkmollman/nagios-better-stack-connector/database"
@@ -12,6 +17,7 @@ import (
 type SQLiteClient struct {
 	db              *sql.DB
 	serialChan      chan struct{}
+	dbPath          string
 	backupDirectory string
 }
 
@@ -24,6 +30,7 @@ func NewSQLiteClient(db_path, backup_directory string) (database.DatabaseClient,
 
 	client := SQLiteClient{
 		db:              db,
+		dbPath:          db_path,
 		backupDirectory: backup_directory,
 		serialChan:      make(chan struct{}, 1),
 	}
@@ -31,11 +38,118 @@ func NewSQLiteClient(db_path, backup_directory string) (database.DatabaseClient,
 	return &client, nil
 }
 
-func (s *SQLiteClient) Backup() {
+func (s *SQLiteClient) Backup() (string, error) {
+	return s.backupWithSuffix("")
+}
+
+func (s *SQLiteClient) backupWithSuffix(suffix string) (string, error) {
 	// generate timestamp
 	timestamp := time.Now().Format("2006-01-02-15-04-05")
-	filestring := s.backupDirectory + "/backup-" + timestamp + ".db"
-	s.db.Exec(`VACUUM INTO "` + filestring + `"`)
+	filestring := s.backupDirectory + "/backup-" + timestamp + suffix + ".db"
+	_, err := s.db.Exec(`VACUUM INTO "` + filestring + `"`)
+	if err != nil {
+		return "", err
+	}
+	return filestring, nil
+}
+
+// Replace the database with a backup, after checking the backup is intact and backing up the current database.
+// Returns the path of the backup of the current database.
+// Nothing else may have the database open, the tables of older backups are migrated by Init.
+func (s *SQLiteClient) Restore(backupPath string) (string, error) {
+	_, err := os.Stat(backupPath)
+	if err != nil {
+		return "", err
+	}
+
+	backup, err := sql.Open("sqlite", backupPath)
+	if err != nil {
+		return "", err
+	}
+	var integrity string
+	err = backup.QueryRow("PRAGMA integrity_check").Scan(&integrity)
+	if err == nil && integrity != "ok" {
+		err = fmt.Errorf("integrity check failed: %s", integrity)
+	}
+	if err == nil {
+		// any connector database has the events table
+		_, err = backup.Exec("SELECT COUNT(*) FROM events")
+	}
+	backup.Close()
+	if err != nil {
+		return "", fmt.Errorf("not a usable backup %s: %w", backupPath, err)
+	}
+
+	// named apart from regular backups, which may have been made in the same second
+	currentBackupPath, err := s.backupWithSuffix("-before-restore")
+	if err != nil {
+		return "", fmt.Errorf("failed to back up the current database: %w", err)
+	}
+
+	// copy next to the database and rename it into place, so a failed copy leaves the database as it was
+	tmpPath := s.dbPath + ".restore"
+	err = copyFile(backupPath, tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+
+	err = s.db.Close()
+	if err != nil {
+		return "", err
+	}
+
+	// a leftover journal of the old database would be applied to the restored one
+	for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+		err = os.Remove(s.dbPath + suffix)
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+
+	err = os.Rename(tmpPath, s.dbPath)
+	if err != nil {
+		return "", err
+	}
+
+	dir, err := os.Open(filepath.Dir(s.dbPath))
+	if err != nil {
+		return "", err
+	}
+	err = dir.Sync()
+	dir.Close()
+	if err != nil {
+		return "", err
+	}
+
+	s.db, err = sql.Open("sqlite", s.dbPath)
+	if err != nil {
+		return "", err
+	}
+	return currentBackupPath, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err == nil {
+		err = out.Sync()
+	}
+	closeErr := out.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
 }
 
 func (s *SQLiteClient) Lock() {
@@ -54,6 +168,42 @@ func (s *SQLiteClient) Init() error {
 	if err != nil {
 		return err
 	}
+	err = s.CreateDowntimeItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateFlappingItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateDestinationItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateCorrelationItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateGroupMemberItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateSuppressionItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreatePendingItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateRecurrenceItemTable()
+	if err != nil {
+		return err
+	}
+	err = s.CreateDecisionItemTable()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -77,14 +227,90 @@ func (s *SQLiteClient) CreateEventItemTable() error {
 		nagiosProblemContent TEXT,
 		nagiosProblemNotificationType TEXT,
 		betterStackPolicyId TEXT,
-		betterStackIncidentId TEXT )`)
+		betterStackIncidentId TEXT,
+		lastCommentedAt INTEGER NOT NULL DEFAULT 0,
+		nagiosProblemState TEXT NOT NULL DEFAULT '',
+		nagiosProblemStateType TEXT NOT NULL DEFAULT '',
+		nagiosProblemAttempt INTEGER NOT NULL DEFAULT 0,
+		nagiosProblemMaxAttempts INTEGER NOT NULL DEFAULT 0,
+		nagiosProblemLongContent TEXT NOT NULL DEFAULT '',
+		nagiosProblemPerfData TEXT NOT NULL DEFAULT '',
+		nagiosNotificationNumber INTEGER NOT NULL DEFAULT 0,
+		nagiosHostGroups TEXT NOT NULL DEFAULT '',
+		nagiosServiceGroups TEXT NOT NULL DEFAULT '' )`)
+
+	if err != nil {
+		return err
+	}
 
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("events", "lastCommentedAt", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = s.addColumnIfMissing("events", "nagiosProblemState", "TEXT NOT NULL DEFAULT ''")
 	if err != nil {
 		return err
 	}
+	err = s.addColumnIfMissing("events", "nagiosProblemStateType", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		return err
+	}
+	for _, column := range []struct{ name, definition string }{
+		{"nagiosProblemAttempt", "INTEGER NOT NULL DEFAULT 0"},
+		{"nagiosProblemMaxAttempts", "INTEGER NOT NULL DEFAULT 0"},
+		{"nagiosProblemLongContent", "TEXT NOT NULL DEFAULT ''"},
+		{"nagiosProblemPerfData", "TEXT NOT NULL DEFAULT ''"},
+		{"nagiosNotificationNumber", "INTEGER NOT NULL DEFAULT 0"},
+		{"nagiosHostGroups", "TEXT NOT NULL DEFAULT ''"},
+		{"nagiosServiceGroups", "TEXT NOT NULL DEFAULT ''"},
+	} {
+		err = s.addColumnIfMissing("events", column.name, column.definition)
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+func (s *SQLiteClient) addColumnIfMissing(table, column, definition string) error {
+	rows, err := s.db.Query(`SELECT name FROM pragma_table_info(?)`, table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	err = rows.Err()
+	if err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = s.db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + definition)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Split a comma separated column, empty for none
+func splitList(list string) []string {
+	if list == "" {
+		return nil
+	}
+	return strings.Split(list, ",")
+}
+
 func (s *SQLiteClient) CreateEventItem(item models.EventItem) (int64, error) {
 	// insert into database
 	insetStmt, err := s.db.Prepare(`
@@ -97,8 +323,18 @@ func (s *SQLiteClient) CreateEventItem(item models.EventItem) (int64, error) {
 		nagiosProblemContent,
 		nagiosProblemNotificationType,
 		betterStackPolicyId,
-		betterStackIncidentId )
-	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		betterStackIncidentId,
+		lastCommentedAt,
+		nagiosProblemState,
+		nagiosProblemStateType,
+		nagiosProblemAttempt,
+		nagiosProblemMaxAttempts,
+		nagiosProblemLongContent,
+		nagiosProblemPerfData,
+		nagiosNotificationNumber,
+		nagiosHostGroups,
+		nagiosServiceGroups )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
 	if err != nil {
 		return 0, err
 	}
@@ -112,6 +348,16 @@ func (s *SQLiteClient) CreateEventItem(item models.EventItem) (int64, error) {
 		item.NagiosProblemNotificationType,
 		item.BetterStackPolicyId,
 		item.BetterStackIncidentId,
+		item.LastCommentedAt,
+		item.NagiosProblemState,
+		item.NagiosProblemStateType,
+		item.NagiosProblemAttempt,
+		item.NagiosProblemMaxAttempts,
+		item.NagiosProblemLongContent,
+		item.NagiosProblemPerfData,
+		item.NagiosNotificationNumber,
+		strings.Join(item.NagiosHostGroups, ","),
+		strings.Join(item.NagiosServiceGroups, ","),
 	)
 	if err != nil {
 		return 0, err
@@ -125,6 +371,68 @@ func (s *SQLiteClient) CreateEventItem(item models.EventItem) (int64, error) {
 	return id, nil
 }
 
+func (s *SQLiteClient) UpdateEventItem(item models.EventItem) (int64, error) {
+	stmt, err := s.db.Prepare(`
+	UPDATE events SET
+		nagiosSiteName = ?,
+		nagiosProblemId = ?,
+		nagiosProblemType = ?,
+		nagiosProblemHostname = ?,
+		nagiosProblemServiceName = ?,
+		nagiosProblemContent = ?,
+		nagiosProblemNotificationType = ?,
+		betterStackPolicyId = ?,
+		betterStackIncidentId = ?,
+		lastCommentedAt = ?,
+		nagiosProblemState = ?,
+		nagiosProblemStateType = ?,
+		nagiosProblemAttempt = ?,
+		nagiosProblemMaxAttempts = ?,
+		nagiosProblemLongContent = ?,
+		nagiosProblemPerfData = ?,
+		nagiosNotificationNumber = ?,
+		nagiosHostGroups = ?,
+		nagiosServiceGroups = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.NagiosSiteName,
+		item.NagiosProblemId,
+		item.NagiosProblemType,
+		item.NagiosProblemHostname,
+		item.NagiosProblemServiceName,
+		item.NagiosProblemContent,
+		item.NagiosProblemNotificationType,
+		item.BetterStackPolicyId,
+		item.BetterStackIncidentId,
+		item.LastCommentedAt,
+		item.NagiosProblemState,
+		item.NagiosProblemStateType,
+		item.NagiosProblemAttempt,
+		item.NagiosProblemMaxAttempts,
+		item.NagiosProblemLongContent,
+		item.NagiosProblemPerfData,
+		item.NagiosNotificationNumber,
+		strings.Join(item.NagiosHostGroups, ","),
+		strings.Join(item.NagiosServiceGroups, ","),
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
 func (s *SQLiteClient) DeleteEventItem(id int64) (int64, error) {
 	stmt, err := s.db.Prepare("DELETE FROM events WHERE id = ?")
 	if err != nil {
@@ -157,7 +465,17 @@ func (s *SQLiteClient) GetAllEventItems() ([]models.EventItem, error) {
 		nagiosProblemContent,
 		nagiosProblemNotificationType,
 		betterStackPolicyId,
-		betterStackIncidentId
+		betterStackIncidentId,
+		lastCommentedAt,
+		nagiosProblemState,
+		nagiosProblemStateType,
+		nagiosProblemAttempt,
+		nagiosProblemMaxAttempts,
+		nagiosProblemLongContent,
+		nagiosProblemPerfData,
+		nagiosNotificationNumber,
+		nagiosHostGroups,
+		nagiosServiceGroups
 	FROM events
 	`)
 	if err != nil {
@@ -174,6 +492,7 @@ func (s *SQLiteClient) GetAllEventItems() ([]models.EventItem, error) {
 	items := []models.EventItem{}
 	for rows.Next() {
 		var item models.EventItem
+		var hostGroups, serviceGroups string
 		err := rows.Scan(
 			&item.Id,
 			&item.NagiosSiteName,
@@ -185,10 +504,22 @@ func (s *SQLiteClient) GetAllEventItems() ([]models.EventItem, error) {
 			&item.NagiosProblemNotificationType,
 			&item.BetterStackPolicyId,
 			&item.BetterStackIncidentId,
+			&item.LastCommentedAt,
+			&item.NagiosProblemState,
+			&item.NagiosProblemStateType,
+			&item.NagiosProblemAttempt,
+			&item.NagiosProblemMaxAttempts,
+			&item.NagiosProblemLongContent,
+			&item.NagiosProblemPerfData,
+			&item.NagiosNotificationNumber,
+			&hostGroups,
+			&serviceGroups,
 		)
 		if err != nil {
 			return nil, err
 		}
+		item.NagiosHostGroups = splitList(hostGroups)
+		item.NagiosServiceGroups = splitList(serviceGroups)
 		items = append(items, item)
 	}
 	return items, nil
@@ -0,0 +1,240 @@
+package sqlitedb
+
+import (
+	"encoding/json"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func (s *SQLiteClient) CreateSuppressionItemTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS suppressions (
+		id INTEGER PRIMARY KEY,
+		name TEXT,
+		nagiosSiteName TEXT,
+		hostPattern TEXT,
+		servicePattern TEXT,
+		betterStackPolicyId TEXT,
+		startsAt INTEGER NOT NULL DEFAULT 0,
+		endsAt INTEGER NOT NULL DEFAULT 0,
+		recurrence TEXT,
+		recursUntil INTEGER NOT NULL DEFAULT 0,
+		comment TEXT,
+		createdBy TEXT,
+		createdAt INTEGER NOT NULL DEFAULT 0,
+		global INTEGER NOT NULL DEFAULT 0,
+		events TEXT NOT NULL DEFAULT '[]' )`)
+
+	if err != nil {
+		return err
+	}
+
+	// tables created by older versions are missing newer columns
+	err = s.addColumnIfMissing("suppressions", "global", "INTEGER NOT NULL DEFAULT 0")
+	if err != nil {
+		return err
+	}
+	err = s.addColumnIfMissing("suppressions", "events", "TEXT NOT NULL DEFAULT '[]'")
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *SQLiteClient) CreateSuppressionItem(item models.SuppressionItem) (int64, error) {
+	events, err := marshalEvents(item.Events)
+	if err != nil {
+		return 0, err
+	}
+
+	insetStmt, err := s.db.Prepare(`
+	INSERT INTO suppressions (
+		name,
+		nagiosSiteName,
+		hostPattern,
+		servicePattern,
+		betterStackPolicyId,
+		startsAt,
+		endsAt,
+		recurrence,
+		recursUntil,
+		comment,
+		createdBy,
+		createdAt,
+		global,
+		events )
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer insetStmt.Close()
+
+	result, err := insetStmt.Exec(
+		item.Name,
+		item.NagiosSiteName,
+		item.HostPattern,
+		item.ServicePattern,
+		item.BetterStackPolicyId,
+		item.StartsAt,
+		item.EndsAt,
+		item.Recurrence,
+		item.RecursUntil,
+		item.Comment,
+		item.CreatedBy,
+		item.CreatedAt,
+		item.Global,
+		events,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQLiteClient) UpdateSuppressionItem(item models.SuppressionItem) (int64, error) {
+	events, err := marshalEvents(item.Events)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := s.db.Prepare(`
+	UPDATE suppressions SET
+		name = ?,
+		nagiosSiteName = ?,
+		hostPattern = ?,
+		servicePattern = ?,
+		betterStackPolicyId = ?,
+		startsAt = ?,
+		endsAt = ?,
+		recurrence = ?,
+		recursUntil = ?,
+		comment = ?,
+		createdBy = ?,
+		createdAt = ?,
+		global = ?,
+		events = ?
+	WHERE id = ?`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(
+		item.Name,
+		item.NagiosSiteName,
+		item.HostPattern,
+		item.ServicePattern,
+		item.BetterStackPolicyId,
+		item.StartsAt,
+		item.EndsAt,
+		item.Recurrence,
+		item.RecursUntil,
+		item.Comment,
+		item.CreatedBy,
+		item.CreatedAt,
+		item.Global,
+		events,
+		item.Id,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) DeleteSuppressionItem(id int64) (int64, error) {
+	stmt, err := s.db.Prepare("DELETE FROM suppressions WHERE id = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	result, err := stmt.Exec(id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsEffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return rowsEffected, nil
+}
+
+func (s *SQLiteClient) GetAllSuppressionItems() ([]models.SuppressionItem, error) {
+	stmt, err := s.db.Prepare(`
+	SELECT
+		id,
+		name,
+		nagiosSiteName,
+		hostPattern,
+		servicePattern,
+		betterStackPolicyId,
+		startsAt,
+		endsAt,
+		recurrence,
+		recursUntil,
+		comment,
+		createdBy,
+		createdAt,
+		global,
+		events
+	FROM suppressions
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.SuppressionItem{}
+	for rows.Next() {
+		var item models.SuppressionItem
+		var events string
+		err := rows.Scan(
+			&item.Id,
+			&item.Name,
+			&item.NagiosSiteName,
+			&item.HostPattern,
+			&item.ServicePattern,
+			&item.BetterStackPolicyId,
+			&item.StartsAt,
+			&item.EndsAt,
+			&item.Recurrence,
+			&item.RecursUntil,
+			&item.Comment,
+			&item.CreatedBy,
+			&item.CreatedAt,
+			&item.Global,
+			&events,
+		)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal([]byte(events), &item.Events)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
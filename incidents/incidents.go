@@ -0,0 +1,77 @@
+package incidents
+
+import (
+	"net/http"
+)
+
+// Provider independent incident states
+const (
+	STATUS_TRIGGERED    = "triggered"
+	STATUS_ACKNOWLEDGED = "acknowledged"
+	STATUS_RESOLVED     = "resolved"
+)
+
+// Provider independent urgencies, mapped to the closest provider severity or priority
+const (
+	URGENCY_CRITICAL = "critical"
+	URGENCY_ERROR    = "error"
+	URGENCY_WARNING  = "warning"
+	URGENCY_INFO     = "info"
+)
+
+// An incident to open for a Nagios problem
+type Incident struct {
+	// unique key of the nagios problem, for providers that deduplicate incidents
+	ProblemKey string
+	// escalation policy, or the provider equivalent (routing key, team)
+	PolicyId    string
+	Name        string
+	Summary     string
+	Description string
+	// host the problem originates from
+	Source string
+	// team owning the incident, for providers that support it, empty for the provider default
+	Team string
+	// one of the URGENCY_ constants, empty for the provider default
+	Urgency string
+	// notify the on-call person by these channels, for providers that support it
+	Call  bool
+	Sms   bool
+	Email bool
+	Push  bool
+	// seconds to wait before escalating to the whole team, for providers that support it, 0 for the provider default
+	TeamWait int
+}
+
+// Reference to an incident opened by a provider
+type IncidentRef struct {
+	Id       string
+	PolicyId string
+}
+
+// Incident state change sent by a provider webhook
+type WebhookEvent struct {
+	IncidentId string
+	// id older versions stored the incident with, empty if it didn't change
+	LegacyIncidentId string
+	// one of the STATUS_ constants, or empty for events the connector doesn't act on
+	Status string
+}
+
+// An incident management service the connector opens incidents in.
+// Implemented by betterstack.BetterStackClient, pagerduty.PagerDutyClient and opsgenie.OpsgenieClient.
+type IncidentProvider interface {
+	Name() string
+	// returns the id of the new incident
+	CreateIncident(incident Incident) (string, error)
+	// contact email may be empty, to use the provider default
+	AcknowledgeIncident(ref IncidentRef, contactEmail string) error
+	// contact email may be empty, to use the provider default
+	ResolveIncident(ref IncidentRef, contactEmail string) error
+	AddIncidentComment(ref IncidentRef, content string) error
+	// returns one of the STATUS_ constants
+	GetIncidentStatus(ref IncidentRef) (string, error)
+	// parse an inbound webhook request, and verify it if the provider supports it
+	ParseWebhook(r *http.Request) (WebhookEvent, error)
+	CheckConnection() error
+}
@@ -0,0 +1,66 @@
+package incidents
+
+import (
+	"fmt"
+)
+
+// A named place incidents are opened at
+type Destination struct {
+	Name     string
+	Provider IncidentProvider
+	// when set, used instead of the policy id sent with notifications, e.g. to page a second team
+	PolicyId string
+}
+
+// Configured destinations, in the order they were registered
+type DestinationRegistry struct {
+	destinations map[string]Destination
+	names        []string
+	defaults     []string
+}
+
+func NewDestinationRegistry() *DestinationRegistry {
+	return &DestinationRegistry{
+		destinations: map[string]Destination{},
+		names:        []string{},
+		defaults:     []string{},
+	}
+}
+
+func (d *DestinationRegistry) Register(destination Destination) {
+	if _, ok := d.destinations[destination.Name]; !ok {
+		d.names = append(d.names, destination.Name)
+	}
+	d.destinations[destination.Name] = destination
+}
+
+func (d *DestinationRegistry) Get(name string) (Destination, error) {
+	destination, ok := d.destinations[name]
+	if !ok {
+		return Destination{}, fmt.Errorf("unknown incident destination %q", name)
+	}
+	return destination, nil
+}
+
+// Registered destination names, in registration order
+func (d *DestinationRegistry) Names() []string {
+	return d.names
+}
+
+// Destinations used for notifications that don't name any, the first registered destination if not set
+func (d *DestinationRegistry) SetDefaults(names []string) error {
+	for _, name := range names {
+		if _, ok := d.destinations[name]; !ok {
+			return fmt.Errorf("unknown incident destination %q", name)
+		}
+	}
+	d.defaults = names
+	return nil
+}
+
+func (d *DestinationRegistry) Defaults() []string {
+	if len(d.defaults) == 0 && len(d.names) > 0 {
+		return d.names[:1]
+	}
+	return d.defaults
+}
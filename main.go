@@ -1,9 +1,169 @@
+// nbsc runs the connector server, and administrative commands against its database and incidents.
+//
+// Every command is configured with the same environment variables as the server.
 package main
 
 import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
 	"github.com/pkmollman/nagios-better-stack-connector/web"
 )
 
+// Exit codes of the commands
+const (
+	EXIT_OK = 0
+	// the command failed, or the health check found problems
+	EXIT_FAILED = 1
+	// unknown command, invalid flags or arguments
+	EXIT_USAGE = 2
+)
+
+const usage = `Usage: nbsc [command]
+
+Commands:
+  serve                      run the server, the default without a command
+  migrate                    create and migrate the database tables
+  backup                     backup the database to SQLITE_DB_BACKUP_DIR_PATH
+  restore <backup-file>      replace the database with a backup
+  events list                list the stored event items
+  events show <id>           show an event item with its incidents as JSON
+  events delete <id>         delete an event item, leaving its incidents alone
+  incident resolve <id>      resolve the incidents of an event item and delete it
+  incident ack <id>          acknowledge the incidents of an event item
+  config check               load and validate the configuration
+  health                     run the health checks once
+
+restore, events delete and the incident commands need the server to be stopped.
+
+Run "nbsc <command> -h" for the flags of a command.
+`
+
 func main() {
-	web.StartServer()
+	args := os.Args[1:]
+	if len(args) == 0 {
+		web.StartServer()
+		return
+	}
+
+	switch args[0] {
+	case "serve":
+		parseFlags("serve", args[1:], 0)
+		web.StartServer()
+	case "migrate":
+		parseFlags("migrate", args[1:], 0)
+		exit(web.Migrate())
+	case "backup":
+		parseFlags("backup", args[1:], 0)
+		exit(web.BackupDatabase())
+	case "restore":
+		flags := parseFlags("restore <backup-file>", args[1:], 1)
+		exit(web.RestoreDatabase(flags.Arg(0)))
+	case "events":
+		eventsCommand(args[1:])
+	case "incident":
+		incidentCommand(args[1:])
+	case "config":
+		if len(args) < 2 || args[1] != "check" {
+			usageError("unknown config command, expected: config check")
+		}
+		parseFlags("config check", args[2:], 0)
+		web.CheckConfig()
+	case "health":
+		parseFlags("health", args[1:], 0)
+		healthy, err := web.CheckHealth()
+		if err == nil && !healthy {
+			os.Exit(EXIT_FAILED)
+		}
+		exit(err)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		usageError("unknown command: " + args[0])
+	}
+}
+
+func eventsCommand(args []string) {
+	if len(args) == 0 {
+		usageError("missing events command, expected: list, show or delete")
+	}
+
+	switch args[0] {
+	case "list":
+		parseFlags("events list", args[1:], 0)
+		exit(web.ListEvents())
+	case "show":
+		flags := parseFlags("events show <id>", args[1:], 1)
+		exit(web.ShowEvent(parseId(flags.Arg(0))))
+	case "delete":
+		flags := parseFlags("events delete <id>", args[1:], 1)
+		exit(web.DeleteEvent(parseId(flags.Arg(0))))
+	default:
+		usageError("unknown events command: " + args[0])
+	}
+}
+
+func incidentCommand(args []string) {
+	if len(args) == 0 {
+		usageError("missing incident command, expected: resolve or ack")
+	}
+
+	flags := flag.NewFlagSet("incident "+args[0]+" <id>", flag.ExitOnError)
+	contactEmail := flags.String("email", "", "contact to label the incident interaction with, defaults to the default contact of the provider")
+
+	switch args[0] {
+	case "resolve":
+		parseFlagSet(flags, args[1:], 1)
+		exit(web.ResolveIncident(parseId(flags.Arg(0)), *contactEmail))
+	case "ack":
+		comment := flags.String("comment", "", "comment to add to the incidents")
+		parseFlagSet(flags, args[1:], 1)
+		exit(web.AcknowledgeIncident(parseId(flags.Arg(0)), *contactEmail, *comment))
+	default:
+		usageError("unknown incident command: " + args[0])
+	}
+}
+
+// Parse the flags of a command without flags of its own, expecting the number of arguments
+func parseFlags(name string, args []string, argCount int) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	parseFlagSet(flags, args, argCount)
+	return flags
+}
+
+func parseFlagSet(flags *flag.FlagSet, args []string, argCount int) {
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: nbsc", flags.Name())
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != argCount {
+		flags.Usage()
+		os.Exit(EXIT_USAGE)
+	}
+}
+
+func parseId(arg string) int64 {
+	id, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		usageError("invalid event item id: " + arg)
+	}
+	return id
+}
+
+func usageError(message string) {
+	fmt.Println(message)
+	fmt.Print(usage)
+	os.Exit(EXIT_USAGE)
+}
+
+func exit(err error) {
+	if err != nil {
+		fmt.Println("ERROR " + err.Error())
+		os.Exit(EXIT_FAILED)
+	}
+	os.Exit(EXIT_OK)
 }
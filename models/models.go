@@ -11,9 +11,33 @@ type EventItem struct {
 	NagiosProblemContent            string `json:"nagiosProblemContent"`
 	// ("PROBLEM", "RECOVERY", "ACKNOWLEDGEMENT", "FLAPPINGSTART", "FLAPPINGSTOP", "FLAPPINGDISABLED", "DOWNTIMESTART", "DOWNTIMEEND", "DOWNTIMECANCELLED")
 	NagiosProblemNotificationType string `json:"nagiosProblemNotificationType"`
-	BetterStackPolicyId           string `json:"betterStackPolicyId"`
-	BetterStackIncidentId         string `json:"betterStackIncidentId"`
-	InteractingUserEmail          string `json:"interactingUserEmail"`
+	// escalation policy, or the incident provider equivalent (PagerDuty routing key, Opsgenie team)
+	BetterStackPolicyId string `json:"betterStackPolicyId"`
+	// id of the incident at the first destination (PagerDuty dedup key, Opsgenie alert id), see DestinationItem for all destinations
+	BetterStackIncidentId string `json:"betterStackIncidentId"`
+	InteractingUserEmail  string `json:"interactingUserEmail"`
+	// comment left with a Nagios acknowledgement, only sent with "ACKNOWLEDGEMENT" notifications
+	NagiosProblemAckComment string `json:"nagiosProblemAckComment"`
+	// unix timestamp of the last plugin output update appended to the incident as a comment
+	LastCommentedAt int64 `json:"lastCommentedAt"`
+	// Nagios state of the host/service ("OK", "WARNING", "CRITICAL", "UNKNOWN", "UP", "DOWN", "UNREACHABLE"), $SERVICESTATE$ or $HOSTSTATE$
+	NagiosProblemState string `json:"nagiosProblemState"`
+	// ("HARD", "SOFT"), $SERVICESTATETYPE$ or $HOSTSTATETYPE$
+	NagiosProblemStateType string `json:"nagiosProblemStateType"`
+	// check attempt and max check attempts, $SERVICEATTEMPT$/$MAXSERVICEATTEMPTS$ or $HOSTATTEMPT$/$MAXHOSTATTEMPTS$
+	NagiosProblemAttempt     int `json:"nagiosProblemAttempt,omitempty"`
+	NagiosProblemMaxAttempts int `json:"nagiosProblemMaxAttempts,omitempty"`
+	// $LONGSERVICEOUTPUT$ or $LONGHOSTOUTPUT$
+	NagiosProblemLongContent string `json:"nagiosProblemLongContent,omitempty"`
+	// $SERVICEPERFDATA$ or $HOSTPERFDATA$
+	NagiosProblemPerfData string `json:"nagiosProblemPerfData,omitempty"`
+	// $SERVICENOTIFICATIONNUMBER$ or $HOSTNOTIFICATIONNUMBER$
+	NagiosNotificationNumber int `json:"nagiosNotificationNumber,omitempty"`
+	// names of the destinations to open incidents at, only sent with notifications, defaults to the configured default destinations
+	IncidentDestinations []string `json:"incidentDestinations,omitempty"`
+	// host groups of the host, and service groups of the service, $HOSTGROUPNAMES$ and $SERVICEGROUPNAMES$ split on commas
+	NagiosHostGroups    []string `json:"nagiosHostGroups,omitempty"`
+	NagiosServiceGroups []string `json:"nagiosServiceGroups,omitempty"`
 }
 
 // json example:
@@ -23,7 +47,166 @@ type EventItem struct {
 // 	"nagiosProblemServiceName": "test-service",
 // 	"nagiosProblemContent": "some problem",
 // 	"nagiosProblemNotificationType": "PROBLEM",
+// 	"nagiosProblemState": "CRITICAL",
+// 	"nagiosProblemStateType": "HARD",
 // 	"betterStackPolicyId": "some-policy-id",
 // 	"nagiosProblemId": 23123,
-// 	"interactingUserEmail": "some-email"
+// 	"interactingUserEmail": "some-email",
+// 	"nagiosProblemAckComment": "looking into it",
+// 	"nagiosProblemAttempt": 3,
+// 	"nagiosProblemMaxAttempts": 3,
+// 	"nagiosProblemLongContent": "more lines of plugin output",
+// 	"nagiosProblemPerfData": "time=0.5s;1;2",
+// 	"nagiosNotificationNumber": 1,
+// 	"nagiosHostGroups": ["linux-servers", "databases"]
 // }
+
+// Active Nagios scheduled downtime, tracked between DOWNTIMESTART and DOWNTIMEEND/DOWNTIMECANCELLED
+type DowntimeItem struct {
+	Id                       int64  `json:"id"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	BetterStackPolicyId      string `json:"betterStackPolicyId"`
+	// unix timestamp
+	StartedAt int64 `json:"startedAt"`
+	// problems suppressed or resolved while the downtime was active, reconciled when it ends. Stored as JSON
+	Events []EventItem `json:"events"`
+}
+
+// Record of a decision the connector made about an incoming notification
+type DecisionItem struct {
+	Id                            int64  `json:"id"`
+	NagiosSiteName                string `json:"nagiosSiteName"`
+	NagiosProblemType             string `json:"nagiosProblemType"`
+	NagiosProblemHostname         string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName      string `json:"nagiosProblemServiceName"`
+	NagiosProblemNotificationType string `json:"nagiosProblemNotificationType"`
+	BetterStackIncidentId         string `json:"betterStackIncidentId"`
+	// ("SUPPRESSED", "RESOLVED", "COMMENTED", "RECREATED", "CREATED", "HELD", "IGNORED", "ESCALATED", "DEESCALATED", "CORRELATED", "GROUPED", "RATE_LIMITED", "PENDING", "FAILED")
+	Decision string `json:"decision"`
+	Reason   string `json:"reason"`
+	// suppression rule that suppressed the notification, 0 for none
+	SuppressionItemId int64 `json:"suppressionItemId,omitempty"`
+	// unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// Nagios host/service that is flapping, tracked between FLAPPINGSTART and FLAPPINGSTOP/FLAPPINGDISABLED
+type FlappingItem struct {
+	Id                       int64  `json:"id"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	BetterStackPolicyId      string `json:"betterStackPolicyId"`
+	// unix timestamp
+	StartedAt int64 `json:"startedAt"`
+}
+
+// Incident opened for an event item at one of its destinations
+type DestinationItem struct {
+	Id          int64 `json:"id"`
+	EventItemId int64 `json:"eventItemId"`
+	// name of the configured destination
+	Destination string `json:"destination"`
+	// incident provider of the destination ("betterstack", "pagerduty", "opsgenie", "chatwebhook")
+	Provider   string `json:"provider"`
+	PolicyId   string `json:"policyId"`
+	IncidentId string `json:"incidentId"`
+	// ("triggered", "acknowledged", "resolved")
+	Status string `json:"status"`
+}
+
+// Problem attached to the open incident of its host, or of a parent host, instead of getting its own incident
+type CorrelationItem struct {
+	Id int64 `json:"id"`
+	// event item of the host incident the problem is attached to
+	ParentEventItemId        int64  `json:"parentEventItemId"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemId          string `json:"nagiosProblemId"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	NagiosProblemContent     string `json:"nagiosProblemContent"`
+	BetterStackPolicyId      string `json:"betterStackPolicyId"`
+	// unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// Problem that is part of a group of problems sharing one incident, see routing rule groupBy
+type GroupMemberItem struct {
+	Id int64 `json:"id"`
+	// event item holding the incident of the group
+	EventItemId              int64  `json:"eventItemId"`
+	GroupKey                 string `json:"groupKey"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemId          string `json:"nagiosProblemId"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	// unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+}
+
+// Rule silencing paging for matching problems, managed at /api/suppressions
+type SuppressionItem struct {
+	Id   int64  `json:"id"`
+	Name string `json:"name"`
+	// matchers, empty ones match anything
+	NagiosSiteName string `json:"nagiosSiteName"`
+	// regular expressions the whole host/service name must match, host problems never match a service pattern
+	HostPattern         string `json:"hostPattern"`
+	ServicePattern      string `json:"servicePattern"`
+	BetterStackPolicyId string `json:"betterStackPolicyId"`
+	// unix timestamps, 0 for no start or end. For recurring rules the first occurrence
+	StartsAt int64 `json:"startsAt"`
+	EndsAt   int64 `json:"endsAt"`
+	// ("", "daily", "weekly")
+	Recurrence string `json:"recurrence"`
+	// unix timestamp after which a recurring rule stops, 0 for never
+	RecursUntil int64  `json:"recursUntil"`
+	Comment     string `json:"comment"`
+	CreatedBy   string `json:"createdBy"`
+	// unix timestamp
+	CreatedAt int64 `json:"createdAt"`
+	// a rule without matchers must be marked global, so it can't silence everything by accident
+	Global bool `json:"global"`
+	// problems suppressed by the rule, reconciled when it ends. Stored as JSON
+	Events []EventItem `json:"events"`
+}
+
+// PROBLEM waiting out the grace period of its routing rule before an incident is opened
+type PendingItem struct {
+	Id                       int64  `json:"id"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	// the notification as received, stored as JSON
+	Event EventItem `json:"event"`
+	// unix timestamps
+	CreatedAt int64 `json:"createdAt"`
+	DueAt     int64 `json:"dueAt"`
+	// failed attempts to open the incident after the grace period, and the error of the last one
+	Attempts  int    `json:"attempts"`
+	LastError string `json:"lastError"`
+}
+
+// Incident history of a host/service, to spot problems that keep coming back after recovery
+type RecurrenceItem struct {
+	Id                       int64  `json:"id"`
+	NagiosSiteName           string `json:"nagiosSiteName"`
+	NagiosProblemType        string `json:"nagiosProblemType"`
+	NagiosProblemHostname    string `json:"nagiosProblemHostname"`
+	NagiosProblemServiceName string `json:"nagiosProblemServiceName"`
+	// incidents opened for the host/service, and how many of them within the recurrence window after a recovery
+	Incidents   int `json:"incidents"`
+	Recurrences int `json:"recurrences"`
+	// incidents last opened for the host/service at each destination, stored as JSON
+	LastIncidents []DestinationItem `json:"lastIncidents"`
+	// unix timestamps, LastRecoveredAt is 0 while the last incident is open
+	LastOpenedAt    int64 `json:"lastOpenedAt"`
+	LastRecoveredAt int64 `json:"lastRecoveredAt"`
+}
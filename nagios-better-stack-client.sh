@@ -1,4 +1,4 @@
-while getopts "u:s:i:c:n:h:t:" flag; do
+while getopts "u:s:i:c:n:h:t:S:T:" flag; do
  case $flag in
    u) # Handle connector endpoint
    CONNECTOR_ENDPOINT=$OPTARG
@@ -21,6 +21,12 @@ while getopts "u:s:i:c:n:h:t:" flag; do
    t) # Handle the -t flag
    NOTIFICATION_TYPE=$OPTARG
    ;;
+   S) # Handle the -S flag, $SERVICESTATE$ or $HOSTSTATE$
+   PROBLEM_STATE=$OPTARG
+   ;;
+   T) # Handle the -T flag, $SERVICESTATETYPE$ or $HOSTSTATETYPE$
+   PROBLEM_STATE_TYPE=$OPTARG
+   ;;
    \?)
    # Handle invalid options
    ;;
@@ -36,5 +42,7 @@ curl -X POST "$CONNECTOR_ENDPOINT" -d "
 	\"nagiosProblemContent\":\"$PROBLEM_CONTENT\",
 	\"nagiosProblemServiceName\": \"$SERVICE_NAME\",
 	\"nagiosProblemHostname\": \"$HOST_NAME\",
-  \"nagiosProblemNotificationType\": \"$NOTIFICATION_TYPE\"
+  \"nagiosProblemNotificationType\": \"$NOTIFICATION_TYPE\",
+	\"nagiosProblemState\": \"$PROBLEM_STATE\",
+	\"nagiosProblemStateType\": \"$PROBLEM_STATE_TYPE\"
 }"
@@ -0,0 +1,244 @@
+package nagios
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type thrukRequest struct {
+	method string
+	path   string
+	query  url.Values
+	header http.Header
+	body   map[string]interface{}
+}
+
+// Thruk REST API test server responding with the status and body, recording the requests it receives
+func newThrukServer(t *testing.T, status int, response string) (*NagiosClient, *[]thrukRequest) {
+	t.Helper()
+
+	requests := []thrukRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := thrukRequest{
+			method: r.Method,
+			path:   r.URL.Path,
+			query:  r.URL.Query(),
+			header: r.Header.Clone(),
+		}
+		if r.ContentLength > 0 {
+			err := json.NewDecoder(r.Body).Decode(&request.body)
+			if err != nil {
+				t.Errorf("invalid request body: %v", err)
+			}
+		}
+		requests = append(requests, request)
+
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewNagiosClient("nbsc", "secret", server.URL, "site-a"), &requests
+}
+
+func TestNagiosClientGetHostState(t *testing.T) {
+	client, requests := newThrukServer(t, http.StatusOK, `[{
+		"display_name": "web 1",
+		"acknowledged": 1,
+		"state": 1,
+		"address": "10.0.0.1",
+		"plugin_output": "CRITICAL - down",
+		"groups": ["web"],
+		"parents": ["switch-1"]
+	}]`)
+
+	host, err := client.GetHostState("web 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host.DisplayName != "web 1" || host.Acknowledged != 1 || host.State != 1 || host.IpAddr != "10.0.0.1" || host.PluginOutput != "CRITICAL - down" {
+		t.Errorf("unexpected host state: %+v", host)
+	}
+	if len(host.Groups) != 1 || host.Groups[0] != "web" || len(host.Parents) != 1 || host.Parents[0] != "switch-1" {
+		t.Errorf("unexpected host groups or parents: %+v", host)
+	}
+
+	request := (*requests)[0]
+	if request.method != "GET" || request.path != "/site-a/thruk/r/hosts" || request.query.Get("name") != "web 1" {
+		t.Errorf("unexpected request: %s %s %v", request.method, request.path, request.query)
+	}
+	if request.header.Get("X-Thruk-Auth-User") != "nbsc" || request.header.Get("X-Thruk-Auth-Key") != "secret" {
+		t.Errorf("missing Thruk authentication headers: %v", request.header)
+	}
+}
+
+func TestNagiosClientGetServiceState(t *testing.T) {
+	client, requests := newThrukServer(t, http.StatusOK, `[{
+		"display_name": "Disk /",
+		"service_description": "disk /",
+		"acknowledged": 0,
+		"state": 2,
+		"plugin_output": "DISK CRITICAL",
+		"host_name": "web-1",
+		"groups": ["disks"],
+		"host_groups": ["web"]
+	}]`)
+
+	service, err := client.GetServiceState("web-1", "disk /")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service.ServiceDesc != "disk /" || service.State != 2 || service.CheckOutput != "DISK CRITICAL" || service.HostName != "web-1" {
+		t.Errorf("unexpected service state: %+v", service)
+	}
+	if len(service.Groups) != 1 || service.Groups[0] != "disks" || len(service.HostGroups) != 1 || service.HostGroups[0] != "web" {
+		t.Errorf("unexpected service groups: %+v", service)
+	}
+
+	request := (*requests)[0]
+	if request.path != "/site-a/thruk/r/services" || request.query.Get("host_name") != "web-1" || request.query.Get("description") != "disk /" {
+		t.Errorf("unexpected request: %s %v", request.path, request.query)
+	}
+}
+
+func TestNagiosClientStateLookupErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{"server error", http.StatusInternalServerError, `{"message": "internal error"}`},
+		{"unauthorized", http.StatusUnauthorized, `{"message": "wrong key"}`},
+		{"not found", http.StatusOK, `[]`},
+		{"invalid json", http.StatusOK, `not json`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newThrukServer(t, test.status, test.response)
+
+			_, err := client.GetHostState("web-1")
+			if err == nil {
+				t.Error("expected an error looking up the host state")
+			}
+
+			_, err = client.GetServiceState("web-1", "disk /")
+			if err == nil {
+				t.Error("expected an error looking up the service state")
+			}
+		})
+	}
+}
+
+func TestNagiosClientCommands(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name string
+		run  func(client *NagiosClient) error
+		path string
+		body map[string]interface{}
+	}{
+		{
+			name: "ack host",
+			run:  func(client *NagiosClient) error { return client.AckHost("web-1", "on it") },
+			path: "/site-a/thruk/r/hosts/web-1/cmd/acknowledge_host_problem",
+			body: map[string]interface{}{"comment_data": "on it"},
+		},
+		{
+			// a query escaped "+" in the path would be a literal plus in the host name
+			name: "ack host with a space in its name",
+			run:  func(client *NagiosClient) error { return client.AckHost("web 1", "on it") },
+			path: "/site-a/thruk/r/hosts/web 1/cmd/acknowledge_host_problem",
+			body: map[string]interface{}{"comment_data": "on it"},
+		},
+		{
+			name: "ack service",
+			run:  func(client *NagiosClient) error { return client.AckService("web-1", "disk /", "on it") },
+			path: "/site-a/thruk/r/cmd",
+			body: map[string]interface{}{"cmd": "acknowledge_svc_problem", "host": "web-1", "service": "disk /", "comment_data": "on it"},
+		},
+		{
+			name: "remove host ack",
+			run:  func(client *NagiosClient) error { return client.RemoveHostAck("web-1") },
+			path: "/site-a/thruk/r/hosts/web-1/cmd/remove_host_acknowledgement",
+		},
+		{
+			name: "remove service ack",
+			run:  func(client *NagiosClient) error { return client.RemoveServiceAck("web-1", "disk /") },
+			path: "/site-a/thruk/r/cmd",
+			body: map[string]interface{}{"cmd": "remove_svc_acknowledgement", "host": "web-1", "service": "disk /"},
+		},
+		{
+			name: "comment host",
+			run:  func(client *NagiosClient) error { return client.CommentHost("web-1", "a note") },
+			path: "/site-a/thruk/r/hosts/web-1/cmd/add_host_comment",
+			body: map[string]interface{}{"persistent": float64(1), "comment_data": "a note"},
+		},
+		{
+			name: "comment service",
+			run:  func(client *NagiosClient) error { return client.CommentService("web-1", "disk /", "a note") },
+			path: "/site-a/thruk/r/cmd",
+			body: map[string]interface{}{"cmd": "add_svc_comment", "host": "web-1", "service": "disk /", "persistent": float64(1), "comment_data": "a note"},
+		},
+		{
+			name: "host downtime",
+			run: func(client *NagiosClient) error {
+				return client.ScheduleHostDowntime("web-1", start, end, "maintenance")
+			},
+			path: "/site-a/thruk/r/hosts/web-1/cmd/schedule_host_downtime",
+			body: map[string]interface{}{"start_time": float64(start.Unix()), "end_time": float64(end.Unix()), "fixed": float64(1), "comment_data": "maintenance"},
+		},
+		{
+			name: "service downtime",
+			run: func(client *NagiosClient) error {
+				return client.ScheduleServiceDowntime("web-1", "disk /", start, end, "maintenance")
+			},
+			path: "/site-a/thruk/r/cmd",
+			body: map[string]interface{}{"cmd": "schedule_svc_downtime", "host": "web-1", "service": "disk /", "start_time": float64(start.Unix()), "end_time": float64(end.Unix()), "fixed": float64(1), "comment_data": "maintenance"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, requests := newThrukServer(t, http.StatusOK, `{"message": "Command successfully submitted"}`)
+
+			err := test.run(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(*requests) != 1 {
+				t.Fatalf("expected 1 request, got %d", len(*requests))
+			}
+			request := (*requests)[0]
+			if request.method != "POST" || request.path != test.path {
+				t.Errorf("unexpected request: %s %s, expected POST %s", request.method, request.path, test.path)
+			}
+			for key, value := range test.body {
+				if request.body[key] != value {
+					t.Errorf("body field %s is %v, expected %v", key, request.body[key], value)
+				}
+			}
+			if len(request.body) != len(test.body) {
+				t.Errorf("unexpected body: %v", request.body)
+			}
+		})
+
+		t.Run(test.name+" error", func(t *testing.T) {
+			client, _ := newThrukServer(t, http.StatusForbidden, `{"message": "not authorized"}`)
+
+			err := test.run(client)
+			if err == nil {
+				t.Error("expected an error for a 403 response")
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package cmdfile
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/extcmd"
+)
+
+// Writes acknowledgements, comments and downtime as external commands to the Nagios command file (nagios.cmd),
+// for Nagios installations without an HTTP API. State lookups are not possible, and return nagios.ErrStateUnsupported.
+type CommandFileClient struct {
+	path string
+	// author of acknowledgements, comments and downtime
+	author string
+	// serialize writes, so commands from concurrent requests don't interleave
+	mutex sync.Mutex
+}
+
+func NewCommandFileClient(path, author string) nagios.MonitoringSource {
+	return &CommandFileClient{
+		path:   path,
+		author: author,
+	}
+}
+
+// Write an external command line formatted by the extcmd package
+func (c *CommandFileClient) write(line string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// don't block when nagios isn't reading the FIFO, fail instead
+	file, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return err
+	}
+
+	// a single write, so nagios never reads half a command
+	_, err = file.WriteString(line + "\n")
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func (c *CommandFileClient) GetHosts() ([]nagios.HostState, error) {
+	return nil, nagios.ErrStateUnsupported
+}
+
+func (c *CommandFileClient) GetHostState(host string) (nagios.HostState, error) {
+	return nagios.HostState{}, nagios.ErrStateUnsupported
+}
+
+func (c *CommandFileClient) GetServiceState(host, service string) (nagios.ServiceState, error) {
+	return nagios.ServiceState{}, nagios.ErrStateUnsupported
+}
+
+func (c *CommandFileClient) AckHost(host, comment string) error {
+	return c.write(extcmd.AckHost(time.Now(), host, c.author, comment))
+}
+
+func (c *CommandFileClient) AckService(host, service, comment string) error {
+	return c.write(extcmd.AckService(time.Now(), host, service, c.author, comment))
+}
+
+func (c *CommandFileClient) RemoveHostAck(host string) error {
+	return c.write(extcmd.RemoveHostAck(time.Now(), host))
+}
+
+func (c *CommandFileClient) RemoveServiceAck(host, service string) error {
+	return c.write(extcmd.RemoveServiceAck(time.Now(), host, service))
+}
+
+func (c *CommandFileClient) CommentHost(host, comment string) error {
+	return c.write(extcmd.CommentHost(time.Now(), host, c.author, comment))
+}
+
+func (c *CommandFileClient) CommentService(host, service, comment string) error {
+	return c.write(extcmd.CommentService(time.Now(), host, service, c.author, comment))
+}
+
+func (c *CommandFileClient) ScheduleHostDowntime(host string, start, end time.Time, comment string) error {
+	return c.write(extcmd.ScheduleHostDowntime(time.Now(), host, start, end, c.author, comment))
+}
+
+func (c *CommandFileClient) ScheduleServiceDowntime(host, service string, start, end time.Time, comment string) error {
+	return c.write(extcmd.ScheduleServiceDowntime(time.Now(), host, service, start, end, c.author, comment))
+}
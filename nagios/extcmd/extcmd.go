@@ -0,0 +1,96 @@
+package extcmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Nagios external commands have no escaping, fields are split on ";" and the command ends at a line break.
+// Names (hosts, services, authors) can't contain either, and are rejected.
+func checkField(field string) error {
+	if strings.ContainsAny(field, ";\r\n") {
+		return fmt.Errorf("external command field %q contains \";\" or a line break", field)
+	}
+	return nil
+}
+
+// Comments are always the last field, which Nagios reads up to the end of the line, so ";" is safe.
+// Line breaks are escaped as a literal \n, like Nagios does for long plugin output.
+func escapeComment(comment string) string {
+	comment = strings.ReplaceAll(comment, "\r\n", "\n")
+	comment = strings.ReplaceAll(comment, "\r", "\n")
+	return strings.ReplaceAll(comment, "\n", `\n`)
+}
+
+// Format an external command without a trailing comment, like "[1712345678] REMOVE_HOST_ACKNOWLEDGEMENT;host"
+func Format(timestamp time.Time, command string, fields ...string) (string, error) {
+	err := checkField(command)
+	if err != nil {
+		return "", err
+	}
+
+	for _, field := range fields {
+		err := checkField(field)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	line := fmt.Sprintf("[%d] %s", timestamp.Unix(), command)
+	if len(fields) > 0 {
+		line += ";" + strings.Join(fields, ";")
+	}
+
+	return line, nil
+}
+
+// Format an external command with a trailing comment, like "[1712345678] ADD_HOST_COMMENT;host;1;author;some comment"
+func FormatWithComment(timestamp time.Time, command, comment string, fields ...string) (string, error) {
+	line, err := Format(timestamp, command, fields...)
+	if err != nil {
+		return "", err
+	}
+
+	return line + ";" + escapeComment(comment), nil
+}
+
+func AckHost(timestamp time.Time, host, author, comment string) (string, error) {
+	// sticky, notify, persistent
+	return FormatWithComment(timestamp, "ACKNOWLEDGE_HOST_PROBLEM", comment, host, "2", "1", "1", author)
+}
+
+func AckService(timestamp time.Time, host, service, author, comment string) (string, error) {
+	// sticky, notify, persistent
+	return FormatWithComment(timestamp, "ACKNOWLEDGE_SVC_PROBLEM", comment, host, service, "2", "1", "1", author)
+}
+
+func RemoveHostAck(timestamp time.Time, host string) (string, error) {
+	return Format(timestamp, "REMOVE_HOST_ACKNOWLEDGEMENT", host)
+}
+
+func RemoveServiceAck(timestamp time.Time, host, service string) (string, error) {
+	return Format(timestamp, "REMOVE_SVC_ACKNOWLEDGEMENT", host, service)
+}
+
+func CommentHost(timestamp time.Time, host, author, comment string) (string, error) {
+	// persistent
+	return FormatWithComment(timestamp, "ADD_HOST_COMMENT", comment, host, "1", author)
+}
+
+func CommentService(timestamp time.Time, host, service, author, comment string) (string, error) {
+	// persistent
+	return FormatWithComment(timestamp, "ADD_SVC_COMMENT", comment, host, service, "1", author)
+}
+
+func ScheduleHostDowntime(timestamp time.Time, host string, start, end time.Time, author, comment string) (string, error) {
+	// fixed, not triggered, duration is ignored for fixed downtime
+	return FormatWithComment(timestamp, "SCHEDULE_HOST_DOWNTIME", comment,
+		host, fmt.Sprint(start.Unix()), fmt.Sprint(end.Unix()), "1", "0", fmt.Sprint(int(end.Sub(start).Seconds())), author)
+}
+
+func ScheduleServiceDowntime(timestamp time.Time, host, service string, start, end time.Time, author, comment string) (string, error) {
+	// fixed, not triggered, duration is ignored for fixed downtime
+	return FormatWithComment(timestamp, "SCHEDULE_SVC_DOWNTIME", comment,
+		host, service, fmt.Sprint(start.Unix()), fmt.Sprint(end.Unix()), "1", "0", fmt.Sprint(int(end.Sub(start).Seconds())), author)
+}
@@ -0,0 +1,138 @@
+package extcmd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCommands(t *testing.T) {
+	timestamp := time.Unix(1712345678, 0)
+	start := time.Unix(1712340000, 0)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		format  func() (string, error)
+		want    string
+		wantErr bool
+	}{
+		{
+			name:   "format without fields",
+			format: func() (string, error) { return Format(timestamp, "SAVE_STATE_INFORMATION") },
+			want:   "[1712345678] SAVE_STATE_INFORMATION",
+		},
+		{
+			name:   "remove host ack",
+			format: func() (string, error) { return RemoveHostAck(timestamp, "web-1") },
+			want:   "[1712345678] REMOVE_HOST_ACKNOWLEDGEMENT;web-1",
+		},
+		{
+			name:   "remove service ack",
+			format: func() (string, error) { return RemoveServiceAck(timestamp, "web-1", "disk /") },
+			want:   "[1712345678] REMOVE_SVC_ACKNOWLEDGEMENT;web-1;disk /",
+		},
+		{
+			name:   "ack host",
+			format: func() (string, error) { return AckHost(timestamp, "web-1", "nbsc", "on it") },
+			want:   "[1712345678] ACKNOWLEDGE_HOST_PROBLEM;web-1;2;1;1;nbsc;on it",
+		},
+		{
+			name:   "ack service",
+			format: func() (string, error) { return AckService(timestamp, "web-1", "disk /", "nbsc", "on it") },
+			want:   "[1712345678] ACKNOWLEDGE_SVC_PROBLEM;web-1;disk /;2;1;1;nbsc;on it",
+		},
+		{
+			name:   "comment host",
+			format: func() (string, error) { return CommentHost(timestamp, "web-1", "nbsc", "a note") },
+			want:   "[1712345678] ADD_HOST_COMMENT;web-1;1;nbsc;a note",
+		},
+		{
+			name:   "comment service",
+			format: func() (string, error) { return CommentService(timestamp, "web-1", "disk /", "nbsc", "a note") },
+			want:   "[1712345678] ADD_SVC_COMMENT;web-1;disk /;1;nbsc;a note",
+		},
+		{
+			name: "host downtime",
+			format: func() (string, error) {
+				return ScheduleHostDowntime(timestamp, "web-1", start, end, "nbsc", "maintenance")
+			},
+			want: "[1712345678] SCHEDULE_HOST_DOWNTIME;web-1;1712340000;1712343600;1;0;3600;nbsc;maintenance",
+		},
+		{
+			name: "service downtime",
+			format: func() (string, error) {
+				return ScheduleServiceDowntime(timestamp, "web-1", "disk /", start, end, "nbsc", "maintenance")
+			},
+			want: "[1712345678] SCHEDULE_SVC_DOWNTIME;web-1;disk /;1712340000;1712343600;1;0;3600;nbsc;maintenance",
+		},
+		{
+			name:   "semicolon in comment is kept",
+			format: func() (string, error) { return CommentHost(timestamp, "web-1", "nbsc", "restarted; still slow") },
+			want:   "[1712345678] ADD_HOST_COMMENT;web-1;1;nbsc;restarted; still slow",
+		},
+		{
+			name: "line breaks in comment are escaped",
+			format: func() (string, error) {
+				return CommentService(timestamp, "web-1", "disk /", "nbsc", "first\nsecond\r\nthird\rfourth")
+			},
+			want: `[1712345678] ADD_SVC_COMMENT;web-1;disk /;1;nbsc;first\nsecond\nthird\nfourth`,
+		},
+		{
+			name:    "semicolon in host",
+			format:  func() (string, error) { return AckHost(timestamp, "web-1;DISABLE_NOTIFICATIONS", "nbsc", "on it") },
+			wantErr: true,
+		},
+		{
+			name:    "semicolon in service",
+			format:  func() (string, error) { return AckService(timestamp, "web-1", "disk;/", "nbsc", "on it") },
+			wantErr: true,
+		},
+		{
+			name:    "line break in host",
+			format:  func() (string, error) { return RemoveHostAck(timestamp, "web-1\n[1712345678] SHUTDOWN_PROGRAM") },
+			wantErr: true,
+		},
+		{
+			name:    "carriage return in service",
+			format:  func() (string, error) { return RemoveServiceAck(timestamp, "web-1", "disk\r/") },
+			wantErr: true,
+		},
+		{
+			name:    "semicolon in author",
+			format:  func() (string, error) { return CommentHost(timestamp, "web-1", "nb;sc", "a note") },
+			wantErr: true,
+		},
+		{
+			name: "line break in author",
+			format: func() (string, error) {
+				return ScheduleHostDowntime(timestamp, "web-1", start, end, "nb\nsc", "maintenance")
+			},
+			wantErr: true,
+		},
+		{
+			name:    "line break in command",
+			format:  func() (string, error) { return Format(timestamp, "SAVE_STATE_INFORMATION\nSHUTDOWN_PROGRAM") },
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			line, err := test.format()
+
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %q", line)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != test.want {
+				t.Errorf("got %q, want %q", line, test.want)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type HostState struct {
@@ -14,7 +15,14 @@ type HostState struct {
 	Acknowledged int      `json:"acknowledged"`
 	State        int      `json:"state"`
 	IpAddr       string   `json:"address"`
+	PluginOutput string   `json:"plugin_output"`
 	Services     []string `json:"services"`
+	Alias        string   `json:"alias"`
+	Groups       []string `json:"groups"`
+	NotesUrl     string   `json:"notes_url_expanded"`
+	ActionUrl    string   `json:"action_url_expanded"`
+	// names of the parent hosts
+	Parents []string `json:"parents"`
 }
 
 func (n *NagiosClient) GetHosts() ([]HostState, error) {
@@ -82,7 +90,7 @@ func (n *NagiosClient) AckHost(host, comment string) error {
 		return err
 	}
 
-	host = url.QueryEscape(host)
+	host = url.PathEscape(host)
 
 	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/hosts/%s/cmd/acknowledge_host_problem", n.siteName, host), bytes.NewReader(jsonBody))
 	if err != nil {
@@ -93,7 +101,96 @@ func (n *NagiosClient) AckHost(host, comment string) error {
 	if err != nil {
 		return err
 	}
-	res.Body.Close()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) ScheduleHostDowntime(host string, start, end time.Time, comment string) error {
+	commandMap := map[string]interface{}{
+		"start_time":   start.Unix(),
+		"end_time":     end.Unix(),
+		"fixed":        1,
+		"comment_data": comment,
+	}
+
+	jsonBody, err := json.Marshal(commandMap)
+	if err != nil {
+		return err
+	}
+
+	host = url.PathEscape(host)
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/hosts/%s/cmd/schedule_host_downtime", n.siteName, host), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) RemoveHostAck(host string) error {
+	host = url.PathEscape(host)
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/hosts/%s/cmd/remove_host_acknowledgement", n.siteName, host), nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) CommentHost(host, comment string) error {
+	commandMap := map[string]interface{}{
+		"persistent":   1,
+		"comment_data": comment,
+	}
+
+	jsonBody, err := json.Marshal(commandMap)
+	if err != nil {
+		return err
+	}
+
+	host = url.PathEscape(host)
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/hosts/%s/cmd/add_host_comment", n.siteName, host), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
 
 	return nil
 }
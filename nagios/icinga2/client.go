@@ -0,0 +1,148 @@
+package icinga2
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+// Icinga 2 REST API client, see https://icinga.com/docs/icinga-2/latest/doc/12-icinga2-api/
+type Icinga2Client struct {
+	apiUser     string
+	apiPassword string
+	baseUrl     string
+	httpClient  *http.Client
+}
+
+func NewIcinga2Client(apiUser, apiPassword, baseUrl string, insecureSkipVerify bool) nagios.MonitoringSource {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// icinga 2 ships with a self signed CA by default
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: insecureSkipVerify}
+
+	return &Icinga2Client{
+		apiUser:     apiUser,
+		apiPassword: apiPassword,
+		baseUrl:     baseUrl,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+func (i *Icinga2Client) NewRequest(httpMethod, endpoint string, data io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(httpMethod, i.baseUrl+endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+
+	req.SetBasicAuth(i.apiUser, i.apiPassword)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// Query objects of a type, decoding each result's attributes into the element type of results
+func (i *Icinga2Client) queryObjects(objectType string, body map[string]interface{}, results interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	// icinga 2 accepts queries with a body as POST, with the method overridden to GET
+	req, err := i.NewRequest("POST", "/v1/objects/"+objectType, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-HTTP-Method-Override", "GET")
+
+	res, err := i.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Icinga 2 returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return json.NewDecoder(res.Body).Decode(results)
+}
+
+// Run an action against the objects matched by the filter
+func (i *Icinga2Client) action(action string, body map[string]interface{}) error {
+	jsonBody, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := i.NewRequest("POST", "/v1/actions/"+action, bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := i.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Icinga 2 returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	var actionResponse struct {
+		Results []struct {
+			Code   float64 `json:"code"`
+			Status string  `json:"status"`
+		} `json:"results"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&actionResponse)
+	if err != nil {
+		return err
+	}
+
+	if len(actionResponse.Results) == 0 {
+		return fmt.Errorf("Icinga 2 %s matched no objects", action)
+	}
+
+	for _, result := range actionResponse.Results {
+		if result.Code != http.StatusOK {
+			return fmt.Errorf("Icinga 2 %s failed: %s", action, result.Status)
+		}
+	}
+
+	return nil
+}
+
+func hostFilter(host string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "Host",
+		"filter":      "host.name == h",
+		"filter_vars": map[string]string{"h": host},
+	}
+}
+
+func serviceFilter(host, service string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":        "Service",
+		"filter":      "host.name == h && service.name == s",
+		"filter_vars": map[string]string{"h": host, "s": service},
+	}
+}
+
+// Add extra fields to an action body
+func withFields(body map[string]interface{}, fields map[string]interface{}) map[string]interface{} {
+	for key, value := range fields {
+		body[key] = value
+	}
+	return body
+}
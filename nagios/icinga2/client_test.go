@@ -0,0 +1,323 @@
+package icinga2
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+type icingaRequest struct {
+	method         string
+	path           string
+	methodOverride string
+	user           string
+	password       string
+	body           map[string]interface{}
+}
+
+// Icinga 2 API test server responding to each path with the status and body, recording the requests it receives
+func newIcingaServer(t *testing.T, status int, responses map[string]string) (nagios.MonitoringSource, *[]icingaRequest) {
+	t.Helper()
+
+	requests := []icingaRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		request := icingaRequest{
+			method:         r.Method,
+			path:           r.URL.Path,
+			methodOverride: r.Header.Get("X-HTTP-Method-Override"),
+			user:           user,
+			password:       password,
+		}
+		err := json.NewDecoder(r.Body).Decode(&request.body)
+		if err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		requests = append(requests, request)
+
+		w.WriteHeader(status)
+		w.Write([]byte(responses[r.URL.Path]))
+	}))
+	t.Cleanup(server.Close)
+
+	return NewIcinga2Client("nbsc", "secret", server.URL, false), &requests
+}
+
+const actionSuccess = `{"results": [{"code": 200, "status": "Successfully acknowledged problem"}]}`
+
+func TestIcinga2GetHostState(t *testing.T) {
+	client, requests := newIcingaServer(t, http.StatusOK, map[string]string{
+		"/v1/objects/hosts": `{"results": [{"attrs": {
+			"name": "web-1",
+			"display_name": "Web server 1",
+			"address": "10.0.0.1",
+			"state": 1,
+			"acknowledgement": 2,
+			"groups": ["web"],
+			"last_check_result": {"output": "CRITICAL - down"}
+		}}]}`,
+	})
+
+	host, err := client.GetHostState("web-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host.DisplayName != "web-1" || host.Alias != "Web server 1" || host.State != 1 || host.IpAddr != "10.0.0.1" {
+		t.Errorf("unexpected host state: %+v", host)
+	}
+	if host.Acknowledged != 1 {
+		t.Errorf("sticky acknowledgement should be acknowledged, got %d", host.Acknowledged)
+	}
+	if host.PluginOutput != "CRITICAL - down" || len(host.Groups) != 1 || host.Groups[0] != "web" {
+		t.Errorf("unexpected host output or groups: %+v", host)
+	}
+
+	request := (*requests)[0]
+	if request.method != "POST" || request.methodOverride != "GET" || request.path != "/v1/objects/hosts" {
+		t.Errorf("unexpected request: %s %s override %q", request.method, request.path, request.methodOverride)
+	}
+	if request.user != "nbsc" || request.password != "secret" {
+		t.Errorf("unexpected basic auth: %s:%s", request.user, request.password)
+	}
+	if request.body["filter"] != "host.name == h" || request.body["filter_vars"].(map[string]interface{})["h"] != "web-1" {
+		t.Errorf("unexpected filter: %v", request.body)
+	}
+}
+
+func TestIcinga2GetServiceState(t *testing.T) {
+	client, requests := newIcingaServer(t, http.StatusOK, map[string]string{
+		"/v1/objects/services": `{"results": [{"attrs": {
+			"name": "disk",
+			"display_name": "Disk /",
+			"host_name": "web-1",
+			"state": 2,
+			"acknowledgement": 0,
+			"groups": ["disks"],
+			"last_check_result": {"output": "DISK CRITICAL"}
+		}, "joins": {"host": {
+			"address": "10.0.0.1",
+			"groups": ["web", "linux"]
+		}}}]}`,
+	})
+
+	service, err := client.GetServiceState("web-1", "disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service.ServiceDesc != "disk" || service.DisplayName != "Disk /" || service.HostName != "web-1" || service.State != 2 {
+		t.Errorf("unexpected service state: %+v", service)
+	}
+	if service.Acknowledged != 0 || service.CheckOutput != "DISK CRITICAL" || len(service.Groups) != 1 || service.Groups[0] != "disks" {
+		t.Errorf("unexpected service acknowledgement, output or groups: %+v", service)
+	}
+
+	if service.HostAddress != "10.0.0.1" || len(service.HostGroups) != 2 || service.HostGroups[0] != "web" || service.HostGroups[1] != "linux" {
+		t.Errorf("unexpected host address or host groups: %+v", service)
+	}
+
+	filterVars := (*requests)[0].body["filter_vars"].(map[string]interface{})
+	if filterVars["h"] != "web-1" || filterVars["s"] != "disk" {
+		t.Errorf("unexpected filter vars: %v", filterVars)
+	}
+	joins, _ := (*requests)[0].body["joins"].([]interface{})
+	if len(joins) != 2 || joins[0] != "host.groups" || joins[1] != "host.address" {
+		t.Errorf("unexpected joins: %v", (*requests)[0].body["joins"])
+	}
+}
+
+func TestIcinga2GetHosts(t *testing.T) {
+	client, _ := newIcingaServer(t, http.StatusOK, map[string]string{
+		"/v1/objects/hosts":    `{"results": [{"attrs": {"name": "web-1"}}, {"attrs": {"name": "web-2"}}]}`,
+		"/v1/objects/services": `{"results": [{"attrs": {"name": "disk", "host_name": "web-1"}}, {"attrs": {"name": "load", "host_name": "web-1"}}]}`,
+	})
+
+	hosts, err := client.GetHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 2 {
+		t.Fatalf("expected 2 hosts, got %d", len(hosts))
+	}
+	if len(hosts[0].Services) != 2 || hosts[0].Services[0] != "disk" || hosts[0].Services[1] != "load" {
+		t.Errorf("unexpected services of web-1: %v", hosts[0].Services)
+	}
+	if hosts[1].Services == nil || len(hosts[1].Services) != 0 {
+		t.Errorf("expected no services for web-2, got %v", hosts[1].Services)
+	}
+}
+
+func TestIcinga2StateLookupErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{"server error", http.StatusInternalServerError, `{"error": 500, "status": "internal error"}`},
+		{"unauthorized", http.StatusUnauthorized, `{"error": 401, "status": "Unauthorized"}`},
+		{"not found", http.StatusOK, `{"results": []}`},
+		{"invalid json", http.StatusOK, `not json`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newIcingaServer(t, test.status, map[string]string{
+				"/v1/objects/hosts":    test.response,
+				"/v1/objects/services": test.response,
+			})
+
+			_, err := client.GetHostState("web-1")
+			if err == nil {
+				t.Error("expected an error looking up the host state")
+			}
+
+			_, err = client.GetServiceState("web-1", "disk")
+			if err == nil {
+				t.Error("expected an error looking up the service state")
+			}
+		})
+	}
+}
+
+func TestIcinga2Actions(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	end := start.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		run        func(client nagios.MonitoringSource) error
+		action     string
+		objectType string
+		fields     map[string]interface{}
+	}{
+		{
+			name:       "ack host",
+			run:        func(client nagios.MonitoringSource) error { return client.AckHost("web-1", "on it") },
+			action:     "acknowledge-problem",
+			objectType: "Host",
+			fields:     map[string]interface{}{"author": "nbsc", "comment": "on it"},
+		},
+		{
+			name:       "ack service",
+			run:        func(client nagios.MonitoringSource) error { return client.AckService("web-1", "disk", "on it") },
+			action:     "acknowledge-problem",
+			objectType: "Service",
+			fields:     map[string]interface{}{"author": "nbsc", "comment": "on it"},
+		},
+		{
+			name:       "remove host ack",
+			run:        func(client nagios.MonitoringSource) error { return client.RemoveHostAck("web-1") },
+			action:     "remove-acknowledgement",
+			objectType: "Host",
+		},
+		{
+			name:       "remove service ack",
+			run:        func(client nagios.MonitoringSource) error { return client.RemoveServiceAck("web-1", "disk") },
+			action:     "remove-acknowledgement",
+			objectType: "Service",
+		},
+		{
+			name:       "comment host",
+			run:        func(client nagios.MonitoringSource) error { return client.CommentHost("web-1", "a note") },
+			action:     "add-comment",
+			objectType: "Host",
+			fields:     map[string]interface{}{"author": "nbsc", "comment": "a note"},
+		},
+		{
+			name:       "comment service",
+			run:        func(client nagios.MonitoringSource) error { return client.CommentService("web-1", "disk", "a note") },
+			action:     "add-comment",
+			objectType: "Service",
+			fields:     map[string]interface{}{"author": "nbsc", "comment": "a note"},
+		},
+		{
+			name: "host downtime",
+			run: func(client nagios.MonitoringSource) error {
+				return client.ScheduleHostDowntime("web-1", start, end, "maintenance")
+			},
+			action:     "schedule-downtime",
+			objectType: "Host",
+			fields:     map[string]interface{}{"comment": "maintenance", "start_time": float64(start.Unix()), "end_time": float64(end.Unix()), "fixed": true},
+		},
+		{
+			name: "service downtime",
+			run: func(client nagios.MonitoringSource) error {
+				return client.ScheduleServiceDowntime("web-1", "disk", start, end, "maintenance")
+			},
+			action:     "schedule-downtime",
+			objectType: "Service",
+			fields:     map[string]interface{}{"comment": "maintenance", "start_time": float64(start.Unix()), "end_time": float64(end.Unix()), "fixed": true},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, requests := newIcingaServer(t, http.StatusOK, map[string]string{
+				"/v1/actions/" + test.action: actionSuccess,
+			})
+
+			err := test.run(client)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			request := (*requests)[0]
+			if request.method != "POST" || request.path != "/v1/actions/"+test.action {
+				t.Errorf("unexpected request: %s %s", request.method, request.path)
+			}
+			if request.body["type"] != test.objectType {
+				t.Errorf("action type is %v, expected %s", request.body["type"], test.objectType)
+			}
+			for key, value := range test.fields {
+				if request.body[key] != value {
+					t.Errorf("body field %s is %v, expected %v", key, request.body[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestIcinga2ActionErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		response string
+	}{
+		{"server error", http.StatusInternalServerError, `{"error": 500, "status": "internal error"}`},
+		{"forbidden", http.StatusForbidden, `{"error": 403, "status": "No permission"}`},
+		{"no objects matched", http.StatusOK, `{"results": []}`},
+		{"failed result", http.StatusOK, `{"results": [{"code": 409, "status": "Object is not in a problem state"}]}`},
+		{"invalid json", http.StatusOK, `not json`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client, _ := newIcingaServer(t, test.status, map[string]string{
+				"/v1/actions/acknowledge-problem": test.response,
+				"/v1/actions/schedule-downtime":   test.response,
+				"/v1/actions/add-comment":         test.response,
+			})
+
+			err := client.AckService("web-1", "disk", "on it")
+			if err == nil {
+				t.Error("expected an error acknowledging")
+			}
+
+			err = client.ScheduleHostDowntime("web-1", time.Now(), time.Now().Add(time.Hour), "maintenance")
+			if err == nil {
+				t.Error("expected an error scheduling downtime")
+			}
+
+			err = client.CommentHost("web-1", "a note")
+			if err == nil {
+				t.Error("expected an error commenting")
+			}
+		})
+	}
+}
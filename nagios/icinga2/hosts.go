@@ -0,0 +1,142 @@
+package icinga2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+type hostAttrs struct {
+	Name            string   `json:"name"`
+	DisplayName     string   `json:"display_name"`
+	Address         string   `json:"address"`
+	State           float64  `json:"state"`
+	Acknowledgement float64  `json:"acknowledgement"`
+	Groups          []string `json:"groups"`
+	NotesUrl        string   `json:"notes_url"`
+	ActionUrl       string   `json:"action_url"`
+	LastCheckResult *struct {
+		Output string `json:"output"`
+	} `json:"last_check_result"`
+}
+
+var hostAttrNames = []string{"name", "display_name", "address", "state", "acknowledgement", "groups", "notes_url", "action_url", "last_check_result"}
+
+type hostResults struct {
+	Results []struct {
+		Attrs hostAttrs `json:"attrs"`
+	} `json:"results"`
+}
+
+func (h hostAttrs) toHostState() nagios.HostState {
+	state := nagios.HostState{
+		// the connector queries hosts by their display name, which is the host name in thruk
+		DisplayName:  h.Name,
+		Acknowledged: 0,
+		State:        int(h.State),
+		IpAddr:       h.Address,
+		Services:     []string{},
+		Alias:        h.DisplayName,
+		Groups:       h.Groups,
+		NotesUrl:     h.NotesUrl,
+		ActionUrl:    h.ActionUrl,
+	}
+
+	// 0 none, 1 normal, 2 sticky
+	if h.Acknowledgement != 0 {
+		state.Acknowledged = 1
+	}
+
+	if h.LastCheckResult != nil {
+		state.PluginOutput = h.LastCheckResult.Output
+	}
+
+	return state
+}
+
+func (i *Icinga2Client) GetHosts() ([]nagios.HostState, error) {
+	var hostResponse hostResults
+	err := i.queryObjects("hosts", map[string]interface{}{
+		"attrs": hostAttrNames,
+	}, &hostResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	var serviceResponse struct {
+		Results []struct {
+			Attrs struct {
+				Name     string `json:"name"`
+				HostName string `json:"host_name"`
+			} `json:"attrs"`
+		} `json:"results"`
+	}
+	err = i.queryObjects("services", map[string]interface{}{
+		"attrs": []string{"name", "host_name"},
+	}, &serviceResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	// icinga 2 hosts don't list their services, like thruk does
+	servicesByHost := map[string][]string{}
+	for _, result := range serviceResponse.Results {
+		servicesByHost[result.Attrs.HostName] = append(servicesByHost[result.Attrs.HostName], result.Attrs.Name)
+	}
+
+	hosts := []nagios.HostState{}
+	for _, result := range hostResponse.Results {
+		host := result.Attrs.toHostState()
+		if services, ok := servicesByHost[result.Attrs.Name]; ok {
+			host.Services = services
+		}
+		hosts = append(hosts, host)
+	}
+
+	return hosts, nil
+}
+
+func (i *Icinga2Client) GetHostState(host string) (nagios.HostState, error) {
+	var hostResponse hostResults
+	err := i.queryObjects("hosts", withFields(hostFilter(host), map[string]interface{}{
+		"attrs": hostAttrNames,
+	}), &hostResponse)
+	if err != nil {
+		return nagios.HostState{}, err
+	}
+
+	if len(hostResponse.Results) != 1 {
+		return nagios.HostState{}, fmt.Errorf("failed to get host state")
+	}
+
+	return hostResponse.Results[0].Attrs.toHostState(), nil
+}
+
+func (i *Icinga2Client) AckHost(host, comment string) error {
+	return i.action("acknowledge-problem", withFields(hostFilter(host), map[string]interface{}{
+		"author":  i.apiUser,
+		"comment": comment,
+	}))
+}
+
+func (i *Icinga2Client) RemoveHostAck(host string) error {
+	return i.action("remove-acknowledgement", hostFilter(host))
+}
+
+func (i *Icinga2Client) CommentHost(host, comment string) error {
+	return i.action("add-comment", withFields(hostFilter(host), map[string]interface{}{
+		"author":  i.apiUser,
+		"comment": comment,
+	}))
+}
+
+func (i *Icinga2Client) ScheduleHostDowntime(host string, start, end time.Time, comment string) error {
+	return i.action("schedule-downtime", withFields(hostFilter(host), map[string]interface{}{
+		"author":     i.apiUser,
+		"comment":    comment,
+		"start_time": start.Unix(),
+		"end_time":   end.Unix(),
+		"fixed":      true,
+	}))
+}
@@ -0,0 +1,101 @@
+package icinga2
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+func (i *Icinga2Client) GetServiceState(host, service string) (nagios.ServiceState, error) {
+	var serviceResponse struct {
+		Results []struct {
+			Attrs struct {
+				Name            string   `json:"name"`
+				DisplayName     string   `json:"display_name"`
+				HostName        string   `json:"host_name"`
+				State           float64  `json:"state"`
+				Acknowledgement float64  `json:"acknowledgement"`
+				Groups          []string `json:"groups"`
+				NotesUrl        string   `json:"notes_url"`
+				ActionUrl       string   `json:"action_url"`
+				LastCheckResult *struct {
+					Output string `json:"output"`
+				} `json:"last_check_result"`
+			} `json:"attrs"`
+			Joins struct {
+				Host struct {
+					Address string   `json:"address"`
+					Groups  []string `json:"groups"`
+				} `json:"host"`
+			} `json:"joins"`
+		} `json:"results"`
+	}
+
+	err := i.queryObjects("services", withFields(serviceFilter(host, service), map[string]interface{}{
+		"attrs": []string{"name", "display_name", "host_name", "state", "acknowledgement", "groups", "notes_url", "action_url", "last_check_result"},
+		// the host attributes services are routed and grouped by
+		"joins": []string{"host.groups", "host.address"},
+	}), &serviceResponse)
+	if err != nil {
+		return nagios.ServiceState{}, err
+	}
+
+	if len(serviceResponse.Results) != 1 {
+		return nagios.ServiceState{}, fmt.Errorf("failed to get service state")
+	}
+
+	attrs := serviceResponse.Results[0].Attrs
+	joinedHost := serviceResponse.Results[0].Joins.Host
+	state := nagios.ServiceState{
+		DisplayName:  attrs.DisplayName,
+		ServiceDesc:  attrs.Name,
+		Acknowledged: 0,
+		State:        int(attrs.State),
+		HostAddress:  joinedHost.Address,
+		HostName:     attrs.HostName,
+		Groups:       attrs.Groups,
+		HostGroups:   joinedHost.Groups,
+		NotesUrl:     attrs.NotesUrl,
+		ActionUrl:    attrs.ActionUrl,
+	}
+
+	// 0 none, 1 normal, 2 sticky
+	if attrs.Acknowledgement != 0 {
+		state.Acknowledged = 1
+	}
+
+	if attrs.LastCheckResult != nil {
+		state.CheckOutput = attrs.LastCheckResult.Output
+	}
+
+	return state, nil
+}
+
+func (i *Icinga2Client) AckService(host, service, comment string) error {
+	return i.action("acknowledge-problem", withFields(serviceFilter(host, service), map[string]interface{}{
+		"author":  i.apiUser,
+		"comment": comment,
+	}))
+}
+
+func (i *Icinga2Client) RemoveServiceAck(host, service string) error {
+	return i.action("remove-acknowledgement", serviceFilter(host, service))
+}
+
+func (i *Icinga2Client) CommentService(host, service, comment string) error {
+	return i.action("add-comment", withFields(serviceFilter(host, service), map[string]interface{}{
+		"author":  i.apiUser,
+		"comment": comment,
+	}))
+}
+
+func (i *Icinga2Client) ScheduleServiceDowntime(host, service string, start, end time.Time, comment string) error {
+	return i.action("schedule-downtime", withFields(serviceFilter(host, service), map[string]interface{}{
+		"author":     i.apiUser,
+		"comment":    comment,
+		"start_time": start.Unix(),
+		"end_time":   end.Unix(),
+		"fixed":      true,
+	}))
+}
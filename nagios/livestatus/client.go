@@ -0,0 +1,172 @@
+package livestatus
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+// MK Livestatus client, see https://docs.checkmk.com/latest/en/livestatus.html
+type LivestatusClient struct {
+	network string
+	address string
+	// author of acknowledgements, comments and downtime
+	author  string
+	timeout time.Duration
+}
+
+// Address is either a unix socket path, like /var/lib/nagios/rw/live, or a TCP host:port, like nagios.acme.com:6557
+func NewLivestatusClient(address, author string) nagios.MonitoringSource {
+	network := "tcp"
+	if strings.HasPrefix(address, "/") {
+		network = "unix"
+	}
+
+	return &LivestatusClient{
+		network: network,
+		address: address,
+		author:  author,
+		timeout: 30 * time.Second,
+	}
+}
+
+func (l *LivestatusClient) dial() (net.Conn, error) {
+	conn, err := net.DialTimeout(l.network, l.address, l.timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conn.SetDeadline(time.Now().Add(l.timeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return conn, nil
+}
+
+// Livestatus reads a query line by line, so values must not contain line breaks
+func checkValue(value string) error {
+	if strings.ContainsAny(value, "\r\n") {
+		return fmt.Errorf("livestatus value %q contains a line break", value)
+	}
+	return nil
+}
+
+// Run a GET query against a table, returning one row per object with the requested columns
+func (l *LivestatusClient) query(table string, columns []string, filters ...string) ([][]interface{}, error) {
+	var query strings.Builder
+	query.WriteString("GET " + table + "\n")
+	query.WriteString("Columns: " + strings.Join(columns, " ") + "\n")
+	for _, filter := range filters {
+		err := checkValue(filter)
+		if err != nil {
+			return nil, err
+		}
+		query.WriteString("Filter: " + filter + "\n")
+	}
+	query.WriteString("OutputFormat: json\n")
+	query.WriteString("ResponseHeader: fixed16\n")
+	query.WriteString("\n")
+
+	conn, err := l.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	_, err = io.WriteString(conn, query.String())
+	if err != nil {
+		return nil, err
+	}
+
+	reader := bufio.NewReader(conn)
+
+	// fixed16 header: 3 digit status code, space, 11 character padded length, newline
+	header := make([]byte, 16)
+	_, err = io.ReadFull(reader, header)
+	if err != nil {
+		return nil, err
+	}
+
+	statusCode, err := strconv.Atoi(string(header[0:3]))
+	if err != nil {
+		return nil, fmt.Errorf("invalid livestatus response header %q", string(header))
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(string(header[4:15])))
+	if err != nil {
+		return nil, fmt.Errorf("invalid livestatus response header %q", string(header))
+	}
+
+	body := make([]byte, length)
+	_, err = io.ReadFull(reader, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if statusCode != 200 {
+		return nil, fmt.Errorf("Livestatus returned status code %d: %s", statusCode, strings.TrimSpace(string(body)))
+	}
+
+	rows := [][]interface{}{}
+	err = json.Unmarshal(body, &rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		if len(row) != len(columns) {
+			return nil, fmt.Errorf("livestatus returned %d columns instead of %d", len(row), len(columns))
+		}
+	}
+
+	return rows, nil
+}
+
+// Send an external command line formatted by the extcmd package
+func (l *LivestatusClient) command(line string, err error) error {
+	if err != nil {
+		return err
+	}
+
+	conn, err := l.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// livestatus doesn't respond to commands
+	_, err = io.WriteString(conn, "COMMAND "+line+"\n\n")
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func asString(value interface{}) string {
+	s, _ := value.(string)
+	return s
+}
+
+func asInt(value interface{}) int {
+	f, _ := value.(float64)
+	return int(f)
+}
+
+func asStrings(value interface{}) []string {
+	values, _ := value.([]interface{})
+	strs := []string{}
+	for _, v := range values {
+		strs = append(strs, asString(v))
+	}
+	return strs
+}
@@ -0,0 +1,255 @@
+package livestatus
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Fake Livestatus unix socket, recording the requests it receives and answering queries with the status and body
+type fakeLivestatus struct {
+	address  string
+	status   int
+	body     string
+	mutex    sync.Mutex
+	requests []string
+	done     chan struct{}
+}
+
+func newFakeLivestatus(t *testing.T, status int, body string) *fakeLivestatus {
+	t.Helper()
+
+	// unix socket paths are limited to around 100 characters, too short for t.TempDir()
+	dir, err := os.MkdirTemp("", "livestatus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	fake := &fakeLivestatus{
+		address: filepath.Join(dir, "live"),
+		status:  status,
+		body:    body,
+		done:    make(chan struct{}, 10),
+	}
+
+	listener, err := net.Listen("unix", fake.address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			fake.serve(conn)
+		}
+	}()
+
+	return fake
+}
+
+// Read a request up to the empty line ending it, and answer it unless it's a command
+func (f *fakeLivestatus) serve(conn net.Conn) {
+	defer conn.Close()
+	defer func() { f.done <- struct{}{} }()
+
+	reader := bufio.NewReader(conn)
+	var request strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		request.WriteString(line)
+		if err != nil || line == "\n" {
+			break
+		}
+	}
+
+	f.mutex.Lock()
+	f.requests = append(f.requests, request.String())
+	f.mutex.Unlock()
+
+	if strings.HasPrefix(request.String(), "COMMAND ") {
+		return
+	}
+
+	fmt.Fprintf(conn, "%03d %11d\n%s", f.status, len(f.body), f.body)
+}
+
+// Requests received, waiting for the connections to be handled
+func (f *fakeLivestatus) received(t *testing.T, count int) []string {
+	t.Helper()
+
+	for i := 0; i < count; i++ {
+		select {
+		case <-f.done:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for request %d", i+1)
+		}
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.requests
+}
+
+func TestLivestatusGetHostState(t *testing.T) {
+	fake := newFakeLivestatus(t, 200, `[["web-1",1,1,"10.0.0.1","CRITICAL - down",["disk","load"],"Web server 1",["web"],"https://notes/web-1","",["switch-1"]]]`+"\n")
+	client := NewLivestatusClient(fake.address, "nbsc")
+
+	host, err := client.GetHostState("web-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if host.DisplayName != "web-1" || host.State != 1 || host.Acknowledged != 1 || host.IpAddr != "10.0.0.1" || host.PluginOutput != "CRITICAL - down" {
+		t.Errorf("unexpected host state: %+v", host)
+	}
+	if host.Alias != "Web server 1" || host.NotesUrl != "https://notes/web-1" || host.ActionUrl != "" {
+		t.Errorf("unexpected host alias or urls: %+v", host)
+	}
+	if len(host.Services) != 2 || host.Services[1] != "load" || len(host.Groups) != 1 || host.Groups[0] != "web" || len(host.Parents) != 1 || host.Parents[0] != "switch-1" {
+		t.Errorf("unexpected host lists: %+v", host)
+	}
+
+	want := "GET hosts\n" +
+		"Columns: " + strings.Join(hostColumns, " ") + "\n" +
+		"Filter: name = web-1\n" +
+		"OutputFormat: json\n" +
+		"ResponseHeader: fixed16\n" +
+		"\n"
+	requests := fake.received(t, 1)
+	if requests[0] != want {
+		t.Errorf("unexpected query:\n%s\nwant:\n%s", requests[0], want)
+	}
+}
+
+func TestLivestatusGetServiceState(t *testing.T) {
+	fake := newFakeLivestatus(t, 200, `[["disk /","Disk /",2,0,"DISK CRITICAL","10.0.0.1","web-1",["disks"],["web"],"",""]]`)
+	client := NewLivestatusClient(fake.address, "nbsc")
+
+	service, err := client.GetServiceState("web-1", "disk /")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service.ServiceDesc != "disk /" || service.DisplayName != "Disk /" || service.State != 2 || service.Acknowledged != 0 {
+		t.Errorf("unexpected service state: %+v", service)
+	}
+	if service.CheckOutput != "DISK CRITICAL" || service.HostAddress != "10.0.0.1" || service.HostName != "web-1" {
+		t.Errorf("unexpected service output or host: %+v", service)
+	}
+	if len(service.Groups) != 1 || service.Groups[0] != "disks" || len(service.HostGroups) != 1 || service.HostGroups[0] != "web" {
+		t.Errorf("unexpected service groups: %+v", service)
+	}
+
+	requests := fake.received(t, 1)
+	if !strings.Contains(requests[0], "Filter: host_name = web-1\nFilter: description = disk /\n") {
+		t.Errorf("unexpected query filters:\n%s", requests[0])
+	}
+}
+
+func TestLivestatusGetHosts(t *testing.T) {
+	fake := newFakeLivestatus(t, 200, `[["web-1",0,0,"","",[],"",[],"","",[]],["web-2",1,0,"","",[],"",[],"","",[]]]`)
+	client := NewLivestatusClient(fake.address, "nbsc")
+
+	hosts, err := client.GetHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(hosts) != 2 || hosts[0].DisplayName != "web-1" || hosts[1].DisplayName != "web-2" || hosts[1].State != 1 {
+		t.Errorf("unexpected hosts: %+v", hosts)
+	}
+
+	requests := fake.received(t, 1)
+	if strings.Contains(requests[0], "Filter:") {
+		t.Errorf("expected no filters:\n%s", requests[0])
+	}
+}
+
+func TestLivestatusQueryErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+	}{
+		{"bad request", 400, "Invalid GET request, missing empty line\n"},
+		{"not found", 200, "[]"},
+		{"wrong column count", 200, `[["web-1",0]]`},
+		{"invalid json", 200, "not json"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := newFakeLivestatus(t, test.status, test.body)
+			client := NewLivestatusClient(fake.address, "nbsc")
+
+			_, err := client.GetHostState("web-1")
+			if err == nil {
+				t.Error("expected an error looking up the host state")
+			}
+		})
+	}
+}
+
+func TestLivestatusRejectsLineBreaksInFilters(t *testing.T) {
+	fake := newFakeLivestatus(t, 200, "[]")
+	client := NewLivestatusClient(fake.address, "nbsc")
+
+	_, err := client.GetServiceState("web-1", "disk\n\nCOMMAND [1712345678] SHUTDOWN_PROGRAM")
+	if err == nil {
+		t.Error("expected an error for a service name with line breaks")
+	}
+}
+
+func TestLivestatusUnreachable(t *testing.T) {
+	client := NewLivestatusClient(filepath.Join(t.TempDir(), "missing"), "nbsc")
+
+	_, err := client.GetHostState("web-1")
+	if err == nil {
+		t.Error("expected an error for a missing socket")
+	}
+}
+
+func TestLivestatusCommands(t *testing.T) {
+	fake := newFakeLivestatus(t, 200, "")
+	client := NewLivestatusClient(fake.address, "nbsc")
+
+	err := client.AckService("web-1", "disk /", "on it; checking\nthe disk")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1712340000, 0)
+	err = client.ScheduleHostDowntime("web-1", start, start.Add(time.Hour), "maintenance")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	requests := fake.received(t, 2)
+
+	ack := strings.SplitN(requests[0], "] ", 2)
+	if !strings.HasPrefix(requests[0], "COMMAND [") || len(ack) != 2 || ack[1] != "ACKNOWLEDGE_SVC_PROBLEM;web-1;disk /;2;1;1;nbsc;on it; checking\\nthe disk\n\n" {
+		t.Errorf("unexpected ack command: %q", requests[0])
+	}
+
+	downtime := strings.SplitN(requests[1], "] ", 2)
+	if len(downtime) != 2 || downtime[1] != "SCHEDULE_HOST_DOWNTIME;web-1;1712340000;1712343600;1;0;3600;nbsc;maintenance\n\n" {
+		t.Errorf("unexpected downtime command: %q", requests[1])
+	}
+
+	// invalid names are rejected without connecting
+	err = client.CommentHost("web-1;SHUTDOWN_PROGRAM", "a note")
+	if err == nil {
+		t.Error("expected an error for a host name with \";\"")
+	}
+}
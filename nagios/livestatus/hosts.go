@@ -0,0 +1,71 @@
+package livestatus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/extcmd"
+)
+
+var hostColumns = []string{"name", "state", "acknowledged", "address", "plugin_output", "services", "alias", "groups", "notes_url_expanded", "action_url_expanded", "parents"}
+
+func rowToHostState(row []interface{}) nagios.HostState {
+	return nagios.HostState{
+		// the connector queries hosts by their display name, which is the host name in thruk
+		DisplayName:  asString(row[0]),
+		State:        asInt(row[1]),
+		Acknowledged: asInt(row[2]),
+		IpAddr:       asString(row[3]),
+		PluginOutput: asString(row[4]),
+		Services:     asStrings(row[5]),
+		Alias:        asString(row[6]),
+		Groups:       asStrings(row[7]),
+		NotesUrl:     asString(row[8]),
+		ActionUrl:    asString(row[9]),
+		Parents:      asStrings(row[10]),
+	}
+}
+
+func (l *LivestatusClient) GetHosts() ([]nagios.HostState, error) {
+	rows, err := l.query("hosts", hostColumns)
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []nagios.HostState{}
+	for _, row := range rows {
+		hosts = append(hosts, rowToHostState(row))
+	}
+
+	return hosts, nil
+}
+
+func (l *LivestatusClient) GetHostState(host string) (nagios.HostState, error) {
+	rows, err := l.query("hosts", hostColumns, "name = "+host)
+	if err != nil {
+		return nagios.HostState{}, err
+	}
+
+	if len(rows) != 1 {
+		return nagios.HostState{}, fmt.Errorf("failed to get host state")
+	}
+
+	return rowToHostState(rows[0]), nil
+}
+
+func (l *LivestatusClient) AckHost(host, comment string) error {
+	return l.command(extcmd.AckHost(time.Now(), host, l.author, comment))
+}
+
+func (l *LivestatusClient) RemoveHostAck(host string) error {
+	return l.command(extcmd.RemoveHostAck(time.Now(), host))
+}
+
+func (l *LivestatusClient) CommentHost(host, comment string) error {
+	return l.command(extcmd.CommentHost(time.Now(), host, l.author, comment))
+}
+
+func (l *LivestatusClient) ScheduleHostDowntime(host string, start, end time.Time, comment string) error {
+	return l.command(extcmd.ScheduleHostDowntime(time.Now(), host, start, end, l.author, comment))
+}
@@ -0,0 +1,53 @@
+package livestatus
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/extcmd"
+)
+
+var serviceColumns = []string{"description", "display_name", "state", "acknowledged", "plugin_output", "host_address", "host_name", "groups", "host_groups", "notes_url_expanded", "action_url_expanded"}
+
+func (l *LivestatusClient) GetServiceState(host, service string) (nagios.ServiceState, error) {
+	rows, err := l.query("services", serviceColumns, "host_name = "+host, "description = "+service)
+	if err != nil {
+		return nagios.ServiceState{}, err
+	}
+
+	if len(rows) != 1 {
+		return nagios.ServiceState{}, fmt.Errorf("failed to get service state")
+	}
+
+	row := rows[0]
+	return nagios.ServiceState{
+		ServiceDesc:  asString(row[0]),
+		DisplayName:  asString(row[1]),
+		State:        asInt(row[2]),
+		Acknowledged: asInt(row[3]),
+		CheckOutput:  asString(row[4]),
+		HostAddress:  asString(row[5]),
+		HostName:     asString(row[6]),
+		Groups:       asStrings(row[7]),
+		HostGroups:   asStrings(row[8]),
+		NotesUrl:     asString(row[9]),
+		ActionUrl:    asString(row[10]),
+	}, nil
+}
+
+func (l *LivestatusClient) AckService(host, service, comment string) error {
+	return l.command(extcmd.AckService(time.Now(), host, service, l.author, comment))
+}
+
+func (l *LivestatusClient) RemoveServiceAck(host, service string) error {
+	return l.command(extcmd.RemoveServiceAck(time.Now(), host, service))
+}
+
+func (l *LivestatusClient) CommentService(host, service, comment string) error {
+	return l.command(extcmd.CommentService(time.Now(), host, service, l.author, comment))
+}
+
+func (l *LivestatusClient) ScheduleServiceDowntime(host, service string, start, end time.Time, comment string) error {
+	return l.command(extcmd.ScheduleServiceDowntime(time.Now(), host, service, start, end, l.author, comment))
+}
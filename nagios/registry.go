@@ -0,0 +1,51 @@
+package nagios
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Named Nagios sites, keyed by the site name sent with notifications (EventItem.NagiosSiteName)
+type SiteRegistry struct {
+	sites map[string]MonitoringSource
+	// used for site names that are not registered, nil to reject them
+	fallback MonitoringSource
+}
+
+func NewSiteRegistry() *SiteRegistry {
+	return &SiteRegistry{
+		sites: map[string]MonitoringSource{},
+	}
+}
+
+func (s *SiteRegistry) Register(name string, client MonitoringSource) {
+	s.sites[name] = client
+}
+
+// Use the client for any site name that is not registered
+func (s *SiteRegistry) SetFallback(client MonitoringSource) {
+	s.fallback = client
+}
+
+func (s *SiteRegistry) Get(name string) (MonitoringSource, error) {
+	client, ok := s.sites[name]
+	if ok {
+		return client, nil
+	}
+
+	if s.fallback != nil {
+		return s.fallback, nil
+	}
+
+	return nil, fmt.Errorf("unknown Nagios site %q", name)
+}
+
+// Registered site names, sorted
+func (s *SiteRegistry) Names() []string {
+	names := []string{}
+	for name := range s.sites {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -6,17 +6,22 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type ServiceState struct {
 	DisplayName string `json:"display_name"`
 	// this is the real service name, for querying the api
-	ServiceDesc  string `json:"service_description"`
-	Acknowledged int    `json:"acknowledged"`
-	State        int    `json:"state"`
-	CheckOutput  string `json:"plugin_output"`
-	HostAddress  string `json:"host_address"`
-	HostName     string `json:"host_name"`
+	ServiceDesc  string   `json:"service_description"`
+	Acknowledged int      `json:"acknowledged"`
+	State        int      `json:"state"`
+	CheckOutput  string   `json:"plugin_output"`
+	HostAddress  string   `json:"host_address"`
+	HostName     string   `json:"host_name"`
+	Groups       []string `json:"groups"`
+	HostGroups   []string `json:"host_groups"`
+	NotesUrl     string   `json:"notes_url_expanded"`
+	ActionUrl    string   `json:"action_url_expanded"`
 }
 
 func (n *NagiosClient) GetServiceState(host, service string) (ServiceState, error) {
@@ -74,7 +79,107 @@ func (n *NagiosClient) AckService(host, service, comment string) error {
 	if err != nil {
 		return err
 	}
-	res.Body.Close()
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) ScheduleServiceDowntime(host, service string, start, end time.Time, comment string) error {
+	commandMap := map[string]interface{}{
+		"cmd":          "schedule_svc_downtime",
+		"host":         host,
+		"service":      service,
+		"start_time":   start.Unix(),
+		"end_time":     end.Unix(),
+		"fixed":        1,
+		"comment_data": comment,
+	}
+
+	jsonBody, err := json.Marshal(commandMap)
+	if err != nil {
+		return err
+	}
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/cmd", n.siteName), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) RemoveServiceAck(host, service string) error {
+	commandMap := map[string]string{
+		"cmd":     "remove_svc_acknowledgement",
+		"host":    host,
+		"service": service,
+	}
+
+	jsonBody, err := json.Marshal(commandMap)
+	if err != nil {
+		return err
+	}
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/cmd", n.siteName), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
+
+	return nil
+}
+
+func (n *NagiosClient) CommentService(host, service, comment string) error {
+	commandMap := map[string]interface{}{
+		"cmd":          "add_svc_comment",
+		"host":         host,
+		"service":      service,
+		"persistent":   1,
+		"comment_data": comment,
+	}
+
+	jsonBody, err := json.Marshal(commandMap)
+	if err != nil {
+		return err
+	}
+
+	req, err := n.NewRequest("POST", fmt.Sprintf("/%s/thruk/r/cmd", n.siteName), bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Nagios returned status code %d instead of %d", res.StatusCode, http.StatusOK)
+	}
 
 	return nil
 }
@@ -0,0 +1,25 @@
+package nagios
+
+import (
+	"errors"
+	"time"
+)
+
+// Returned by state lookups of backends that can only send commands, like the external command file
+var ErrStateUnsupported = errors.New("state lookups are not supported by this monitoring source")
+
+// A monitoring backend the connector can query and send commands to, for a single site.
+// Implemented by NagiosClient (Thruk REST API), and the backends in the subpackages.
+type MonitoringSource interface {
+	GetHosts() ([]HostState, error)
+	GetHostState(host string) (HostState, error)
+	GetServiceState(host, service string) (ServiceState, error)
+	AckHost(host, comment string) error
+	AckService(host, service, comment string) error
+	RemoveHostAck(host string) error
+	RemoveServiceAck(host, service string) error
+	CommentHost(host, comment string) error
+	CommentService(host, service, comment string) error
+	ScheduleHostDowntime(host string, start, end time.Time, comment string) error
+	ScheduleServiceDowntime(host, service string, start, end time.Time, comment string) error
+}
@@ -0,0 +1,28 @@
+package nagios
+
+// Name of a host or service state, as Nagios prints it in notifications ($HOSTSTATE$, $SERVICESTATE$)
+func StateName(problemType string, state int) string {
+	if problemType == "HOST" {
+		switch state {
+		case 0:
+			return "UP"
+		case 1:
+			return "DOWN"
+		case 2:
+			return "UNREACHABLE"
+		}
+		return ""
+	}
+
+	switch state {
+	case 0:
+		return "OK"
+	case 1:
+		return "WARNING"
+	case 2:
+		return "CRITICAL"
+	case 3:
+		return "UNKNOWN"
+	}
+	return ""
+}
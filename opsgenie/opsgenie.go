@@ -0,0 +1,281 @@
+package opsgenie
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+)
+
+// Opsgenie Alert API client. Alerts are identified by their alias, the problem key, so opening one doesn't wait
+// for Opsgenie to process the request. The policy id is the name of the team the alert is assigned to.
+type OpsgenieClient struct {
+	apiKey  string
+	baseUrl string
+	// user to label alert interactions with, when no other contact is known
+	defaultUser string
+	// shared secret expected in the X-Webhook-Token header of outgoing webhooks, optional
+	webhookToken string
+	httpClient   *http.Client
+}
+
+func NewOpsgenieClient(apiKey, baseUrl, defaultUser, webhookToken string) *OpsgenieClient {
+	return &OpsgenieClient{
+		apiKey:       apiKey,
+		baseUrl:      baseUrl,
+		defaultUser:  defaultUser,
+		webhookToken: webhookToken,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (o *OpsgenieClient) Name() string {
+	return "opsgenie"
+}
+
+func (o *OpsgenieClient) NewRequest(httpMethod, endpoint string, data io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(httpMethod, o.baseUrl+endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "GenieKey "+o.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	return req, nil
+}
+
+// Send a request, and decode the response into result if it is not nil
+func (o *OpsgenieClient) do(httpMethod, endpoint string, body interface{}, expectedStatusCode int, result interface{}) error {
+	var bodyReader io.Reader
+	if body != nil {
+		jsonBody, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		bodyReader = bytes.NewReader(jsonBody)
+	}
+
+	req, err := o.NewRequest(httpMethod, endpoint, bodyReader)
+	if err != nil {
+		return err
+	}
+
+	res, err := o.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != expectedStatusCode {
+		return fmt.Errorf("response status code was %d", res.StatusCode)
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// Prefix of incident ids holding an alert alias, alerts opened by older versions are identified by their alert id
+const aliasPrefix = "alias:"
+
+// Identifier and identifier type of the alert of an incident
+func alertIdentifier(ref incidents.IncidentRef) (string, string) {
+	if strings.HasPrefix(ref.Id, aliasPrefix) {
+		return strings.TrimPrefix(ref.Id, aliasPrefix), "alias"
+	}
+	return ref.Id, "id"
+}
+
+type restAlert struct {
+	Id           string `json:"id"`
+	Status       string `json:"status"`
+	Acknowledged bool   `json:"acknowledged"`
+}
+
+// Find the newest alert with an alias, closed alerts included
+func (o *OpsgenieClient) findAlert(alias string) (restAlert, error) {
+	var alertsResponse struct {
+		Data []restAlert `json:"data"`
+	}
+
+	query := url.QueryEscape(`alias:"` + alias + `"`)
+	err := o.do("GET", "/v2/alerts?query="+query+"&sort=createdAt&order=desc&limit=1", nil, http.StatusOK, &alertsResponse)
+	if err != nil {
+		return restAlert{}, err
+	}
+
+	// alert requests are processed asynchronously, the alert may not exist yet
+	if len(alertsResponse.Data) == 0 {
+		return restAlert{}, fmt.Errorf("no Opsgenie alert found for alias %q", alias)
+	}
+
+	return alertsResponse.Data[0], nil
+}
+
+// Opsgenie priority for an urgency, P1 when not set
+func priority(urgency string) string {
+	switch urgency {
+	case incidents.URGENCY_ERROR:
+		return "P2"
+	case incidents.URGENCY_WARNING:
+		return "P3"
+	case incidents.URGENCY_INFO:
+		return "P5"
+	}
+	return "P1"
+}
+
+func (o *OpsgenieClient) CreateIncident(incident incidents.Incident) (string, error) {
+	message := incident.Name + " " + incident.Summary
+	// opsgenie rejects messages over 130 characters
+	if len([]rune(message)) > 130 {
+		message = string([]rune(message)[:127]) + "..."
+	}
+
+	if incident.ProblemKey == "" {
+		return "", fmt.Errorf("Opsgenie alerts need a problem key to use as alias")
+	}
+
+	alert := map[string]interface{}{
+		"message":     message,
+		"alias":       incident.ProblemKey,
+		"description": incident.Description,
+		"entity":      incident.Source,
+		"source":      "nagios",
+		"user":        o.defaultUser,
+		"priority":    priority(incident.Urgency),
+	}
+
+	team := incident.PolicyId
+	if incident.Team != "" {
+		team = incident.Team
+	}
+
+	if team != "" {
+		alert["responders"] = []map[string]string{
+			{"name": team, "type": "team"},
+		}
+	}
+
+	err := o.do("POST", "/v2/alerts", alert, http.StatusAccepted, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return aliasPrefix + incident.ProblemKey, nil
+}
+
+func (o *OpsgenieClient) alertAction(ref incidents.IncidentRef, action, user, note string) error {
+	if user == "" {
+		user = o.defaultUser
+	}
+
+	body := map[string]string{
+		"user":   user,
+		"source": "nagios",
+	}
+	if note != "" {
+		body["note"] = note
+	}
+
+	// actions by alias apply to the open alert with the alias, once Opsgenie processed the request creating it
+	identifier, identifierType := alertIdentifier(ref)
+	return o.do("POST", "/v2/alerts/"+url.PathEscape(identifier)+"/"+action+"?identifierType="+identifierType, body, http.StatusAccepted, nil)
+}
+
+func (o *OpsgenieClient) AcknowledgeIncident(ref incidents.IncidentRef, contactEmail string) error {
+	return o.alertAction(ref, "acknowledge", contactEmail, "")
+}
+
+func (o *OpsgenieClient) ResolveIncident(ref incidents.IncidentRef, contactEmail string) error {
+	return o.alertAction(ref, "close", contactEmail, "")
+}
+
+func (o *OpsgenieClient) AddIncidentComment(ref incidents.IncidentRef, content string) error {
+	return o.alertAction(ref, "notes", "", content)
+}
+
+func (o *OpsgenieClient) GetIncidentStatus(ref incidents.IncidentRef) (string, error) {
+	var alertResponse struct {
+		Data restAlert `json:"data"`
+	}
+
+	// getting an alert by alias only finds open alerts, so search for it
+	identifier, identifierType := alertIdentifier(ref)
+	var err error
+	if identifierType == "alias" {
+		alertResponse.Data, err = o.findAlert(identifier)
+	} else {
+		err = o.do("GET", "/v2/alerts/"+url.PathEscape(identifier)+"?identifierType=id", nil, http.StatusOK, &alertResponse)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case alertResponse.Data.Status == "closed":
+		return incidents.STATUS_RESOLVED, nil
+	case alertResponse.Data.Acknowledged:
+		return incidents.STATUS_ACKNOWLEDGED, nil
+	default:
+		return incidents.STATUS_TRIGGERED, nil
+	}
+}
+
+// Parse an outgoing webhook integration request
+func (o *OpsgenieClient) ParseWebhook(r *http.Request) (incidents.WebhookEvent, error) {
+	if o.webhookToken != "" &&
+		subtle.ConstantTimeCompare([]byte(r.Header.Get("X-Webhook-Token")), []byte(o.webhookToken)) != 1 {
+		return incidents.WebhookEvent{}, fmt.Errorf("invalid Opsgenie webhook token")
+	}
+
+	var payload struct {
+		Action string `json:"action"`
+		Alert  struct {
+			AlertId string `json:"alertId"`
+			Alias   string `json:"alias"`
+		} `json:"alert"`
+	}
+
+	err := json.NewDecoder(r.Body).Decode(&payload)
+	if err != nil {
+		return incidents.WebhookEvent{}, err
+	}
+
+	webhookEvent := incidents.WebhookEvent{
+		IncidentId: payload.Alert.AlertId,
+	}
+	if payload.Alert.Alias != "" {
+		webhookEvent.IncidentId = aliasPrefix + payload.Alert.Alias
+		webhookEvent.LegacyIncidentId = payload.Alert.AlertId
+	}
+
+	switch payload.Action {
+	case "Acknowledge":
+		webhookEvent.Status = incidents.STATUS_ACKNOWLEDGED
+	case "Close":
+		webhookEvent.Status = incidents.STATUS_RESOLVED
+	}
+
+	return webhookEvent, nil
+}
+
+func (o *OpsgenieClient) CheckConnection() error {
+	err := o.do("GET", "/v2/alerts?limit=1", nil, http.StatusOK, nil)
+	if err != nil {
+		return fmt.Errorf("Failed to request /v2/alerts: %s", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,338 @@
+package pagerduty
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+)
+
+// PagerDuty Events API v2 client, with optional REST API access for comments and status lookups.
+// Incidents are identified by their dedup key, and the policy id is the integration routing key.
+type PagerDutyClient struct {
+	eventsUrl string
+	restUrl   string
+	// REST API token, optional
+	apiToken string
+	// REST API requests that change incidents need a "From" user email
+	fromEmail string
+	// webhooks v3 signing secret, optional
+	webhookSecret string
+	httpClient    *http.Client
+}
+
+func NewPagerDutyClient(eventsUrl, restUrl, apiToken, fromEmail, webhookSecret string) *PagerDutyClient {
+	return &PagerDutyClient{
+		eventsUrl:     eventsUrl,
+		restUrl:       restUrl,
+		apiToken:      apiToken,
+		fromEmail:     fromEmail,
+		webhookSecret: webhookSecret,
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+		},
+	}
+}
+
+func (p *PagerDutyClient) Name() string {
+	return "pagerduty"
+}
+
+// Send an event to the Events API v2
+func (p *PagerDutyClient) enqueue(event map[string]interface{}) (string, error) {
+	jsonBody, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", p.eventsUrl+"/v2/enqueue", bytes.NewReader(jsonBody))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	var enqueueResponse struct {
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+		DedupKey string `json:"dedup_key"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&enqueueResponse)
+	if err != nil {
+		return "", fmt.Errorf("response status code was %d: %s", res.StatusCode, err.Error())
+	}
+
+	if res.StatusCode != http.StatusAccepted {
+		return "", fmt.Errorf("response status code was %d: %s", res.StatusCode, enqueueResponse.Message)
+	}
+
+	return enqueueResponse.DedupKey, nil
+}
+
+func (p *PagerDutyClient) CreateIncident(incident incidents.Incident) (string, error) {
+	// the urgency constants are PagerDuty severities
+	severity := incident.Urgency
+	if severity == "" {
+		severity = incidents.URGENCY_CRITICAL
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  incident.PolicyId,
+		"event_action": "trigger",
+		"payload": map[string]interface{}{
+			"summary":  incident.Name + " " + incident.Summary,
+			"source":   incident.Source,
+			"severity": severity,
+			"custom_details": map[string]string{
+				"description": incident.Description,
+			},
+		},
+	}
+
+	if incident.ProblemKey != "" {
+		event["dedup_key"] = incident.ProblemKey
+	}
+
+	return p.enqueue(event)
+}
+
+func (p *PagerDutyClient) AcknowledgeIncident(ref incidents.IncidentRef, contactEmail string) error {
+	_, err := p.enqueue(map[string]interface{}{
+		"routing_key":  ref.PolicyId,
+		"event_action": "acknowledge",
+		"dedup_key":    ref.Id,
+	})
+	return err
+}
+
+func (p *PagerDutyClient) ResolveIncident(ref incidents.IncidentRef, contactEmail string) error {
+	_, err := p.enqueue(map[string]interface{}{
+		"routing_key":  ref.PolicyId,
+		"event_action": "resolve",
+		"dedup_key":    ref.Id,
+	})
+	return err
+}
+
+func (p *PagerDutyClient) NewRestRequest(httpMethod, endpoint string, data io.Reader) (*http.Request, error) {
+	if p.apiToken == "" {
+		return nil, fmt.Errorf("PagerDuty REST API token is not configured")
+	}
+
+	req, err := http.NewRequest(httpMethod, p.restUrl+endpoint, data)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Token token="+p.apiToken)
+	req.Header.Set("Accept", "application/vnd.pagerduty+json;version=2")
+	req.Header.Set("Content-Type", "application/json")
+	if p.fromEmail != "" {
+		req.Header.Set("From", p.fromEmail)
+	}
+
+	return req, nil
+}
+
+type restIncident struct {
+	Id        string    `json:"id"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Look up the REST API incident for a dedup key. Recreated and recurring problems reuse their dedup key, so the
+// key can belong to older resolved incidents as well, the newest incident is the one for the event item.
+func (p *PagerDutyClient) findIncident(dedupKey string) (restIncident, error) {
+	req, err := p.NewRestRequest("GET", "/incidents?incident_key="+url.QueryEscape(dedupKey)+"&date_range=all&sort_by=created_at:desc", nil)
+	if err != nil {
+		return restIncident{}, err
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return restIncident{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return restIncident{}, fmt.Errorf("response status code was %d", res.StatusCode)
+	}
+
+	var incidentsResponse struct {
+		Incidents []restIncident `json:"incidents"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&incidentsResponse)
+	if err != nil {
+		return restIncident{}, err
+	}
+
+	if len(incidentsResponse.Incidents) == 0 {
+		return restIncident{}, fmt.Errorf("no PagerDuty incident found for dedup key %q", dedupKey)
+	}
+
+	newest := incidentsResponse.Incidents[0]
+	for _, incident := range incidentsResponse.Incidents[1:] {
+		if incident.CreatedAt.After(newest.CreatedAt) {
+			newest = incident
+		}
+	}
+
+	return newest, nil
+}
+
+func (p *PagerDutyClient) AddIncidentComment(ref incidents.IncidentRef, content string) error {
+	incident, err := p.findIncident(ref.Id)
+	if err != nil {
+		return err
+	}
+
+	var note struct {
+		Note struct {
+			Content string `json:"content"`
+		} `json:"note"`
+	}
+	note.Note.Content = content
+
+	jsonBody, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+
+	req, err := p.NewRestRequest("POST", "/incidents/"+incident.Id+"/notes", bytes.NewReader(jsonBody))
+	if err != nil {
+		return err
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusCreated {
+		return fmt.Errorf("response status code was %d", res.StatusCode)
+	}
+
+	return nil
+}
+
+func (p *PagerDutyClient) GetIncidentStatus(ref incidents.IncidentRef) (string, error) {
+	incident, err := p.findIncident(ref.Id)
+	if err != nil {
+		return "", err
+	}
+
+	switch incident.Status {
+	case "acknowledged":
+		return incidents.STATUS_ACKNOWLEDGED, nil
+	case "resolved":
+		return incidents.STATUS_RESOLVED, nil
+	default:
+		return incidents.STATUS_TRIGGERED, nil
+	}
+}
+
+type webhookPayload struct {
+	Event struct {
+		EventType    string `json:"event_type"`
+		ResourceType string `json:"resource_type"`
+		Data         struct {
+			Id          string `json:"id"`
+			IncidentKey string `json:"incident_key"`
+			Status      string `json:"status"`
+		} `json:"data"`
+	} `json:"event"`
+}
+
+// Verify the X-PagerDuty-Signature header, which holds one or more comma separated "v1=<hex hmac sha256>" signatures
+func (p *PagerDutyClient) verifySignature(body []byte, header string) error {
+	mac := hmac.New(sha256.New, []byte(p.webhookSecret))
+	mac.Write(body)
+	expected := "v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	for _, signature := range strings.Split(header, ",") {
+		if hmac.Equal([]byte(strings.TrimSpace(signature)), []byte(expected)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("invalid PagerDuty webhook signature")
+}
+
+// Parse a webhooks v3 request
+func (p *PagerDutyClient) ParseWebhook(r *http.Request) (incidents.WebhookEvent, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return incidents.WebhookEvent{}, err
+	}
+
+	if p.webhookSecret != "" {
+		err = p.verifySignature(body, r.Header.Get("X-PagerDuty-Signature"))
+		if err != nil {
+			return incidents.WebhookEvent{}, err
+		}
+	}
+
+	var payload webhookPayload
+	err = json.Unmarshal(body, &payload)
+	if err != nil {
+		return incidents.WebhookEvent{}, err
+	}
+
+	if payload.Event.ResourceType != "incident" {
+		return incidents.WebhookEvent{}, nil
+	}
+
+	webhookEvent := incidents.WebhookEvent{
+		// incidents are stored by their dedup key
+		IncidentId: payload.Event.Data.IncidentKey,
+	}
+
+	switch payload.Event.EventType {
+	case "incident.acknowledged":
+		webhookEvent.Status = incidents.STATUS_ACKNOWLEDGED
+	case "incident.resolved":
+		webhookEvent.Status = incidents.STATUS_RESOLVED
+	}
+
+	return webhookEvent, nil
+}
+
+func (p *PagerDutyClient) CheckConnection() error {
+	// the events API has no read endpoint, so only the REST API can be checked
+	if p.apiToken == "" {
+		return nil
+	}
+
+	req, err := p.NewRestRequest("GET", "/abilities", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to request /abilities: %s", err.Error())
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("Response status code for /abilities was %d, not 200", res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package routing
+
+import (
+	"slices"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+)
+
+// Nagios problem states channel settings can be given for
+var StateNames = []string{"WARNING", "CRITICAL", "UNKNOWN", "DOWN", "UNREACHABLE"}
+
+func IsStateName(state string) bool {
+	return slices.Contains(StateNames, state)
+}
+
+// On-call notification settings for an incident, unset fields leave the incident as it is
+type Channels struct {
+	Call  *bool `json:"call,omitempty"`
+	Sms   *bool `json:"sms,omitempty"`
+	Email *bool `json:"email,omitempty"`
+	Push  *bool `json:"push,omitempty"`
+	// seconds to wait before escalating to the whole team
+	TeamWait *int `json:"teamWait,omitempty"`
+}
+
+// Whether any setting is set
+func (c Channels) IsSet() bool {
+	return c.Call != nil || c.Sms != nil || c.Email != nil || c.Push != nil || c.TeamWait != nil
+}
+
+func (c Channels) Apply(incident *incidents.Incident) {
+	if c.Call != nil {
+		incident.Call = *c.Call
+	}
+	if c.Sms != nil {
+		incident.Sms = *c.Sms
+	}
+	if c.Email != nil {
+		incident.Email = *c.Email
+	}
+	if c.Push != nil {
+		incident.Push = *c.Push
+	}
+	if c.TeamWait != nil {
+		incident.TeamWait = *c.TeamWait
+	}
+}
+
+// Channel settings by Nagios state name ("CRITICAL", "WARNING", "UNKNOWN", "DOWN", "UNREACHABLE"),
+// with "" holding the settings for all states
+type StateChannels map[string]Channels
+
+// Apply the settings for all states, then those of the state
+func (s StateChannels) Apply(incident *incidents.Incident, state string) {
+	s[""].Apply(incident)
+	if state != "" {
+		s[state].Apply(incident)
+	}
+}
+
+// Whether any settings depend on the state
+func (s StateChannels) NeedsState() bool {
+	for state, channels := range s {
+		if state != "" && channels.IsSet() {
+			return true
+		}
+	}
+	return false
+}
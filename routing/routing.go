@@ -0,0 +1,288 @@
+package routing
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// What a rule matches. Empty fields match anything, and a rule only matches when all set fields do.
+type Match struct {
+	// Nagios site names, any of
+	Sites []string `json:"sites,omitempty"`
+	// regular expression the whole host name must match
+	Host string `json:"host,omitempty"`
+	// regular expression the whole service name must match, host problems never match it
+	Service string `json:"service,omitempty"`
+	// host groups of the host, any of
+	HostGroups []string `json:"hostGroups,omitempty"`
+	// service groups of the service, any of
+	ServiceGroups []string `json:"serviceGroups,omitempty"`
+	// regular expression matched anywhere in the plugin output
+	Content string `json:"content,omitempty"`
+
+	host    *regexp.Regexp
+	service *regexp.Regexp
+	content *regexp.Regexp
+}
+
+// A routing rule, picking where and how incidents for the Nagios problems it matches are opened
+type Rule struct {
+	Name  string `json:"name"`
+	Match Match  `json:"match"`
+	// escalation policy, or the incident provider equivalent (PagerDuty routing key, Opsgenie team)
+	PolicyId string `json:"policyId,omitempty"`
+	// team owning the incident (Better Stack team name, Opsgenie team)
+	Team string `json:"team,omitempty"`
+	// one of the incidents.URGENCY_ constants
+	Urgency string `json:"urgency,omitempty"`
+	// on-call notification channels and team wait, unset settings are taken from the global configuration
+	Channels
+	// channel settings by Nagios state, e.g. "CRITICAL", on top of the settings above
+	States StateChannels `json:"states,omitempty"`
+	// destinations to open incidents at, when the notification doesn't name any
+	Destinations []string `json:"destinations,omitempty"`
+	// incident name, summary and description templates, instead of the global ones
+	Templates
+	// problems with the same group key arriving within the window share one incident, see GroupKey
+	GroupBy            string `json:"groupBy,omitempty"`
+	GroupWindowSeconds int    `json:"groupWindowSeconds,omitempty"`
+	// seconds a PROBLEM waits for its RECOVERY before an incident is opened
+	GraceSeconds int `json:"graceSeconds,omitempty"`
+}
+
+// What problems can be grouped by
+const (
+	GROUP_BY_RULE         = "rule"
+	GROUP_BY_SITE         = "site"
+	GROUP_BY_HOST         = "host"
+	GROUP_BY_SERVICE      = "service"
+	GROUP_BY_HOSTGROUP    = "hostgroup"
+	GROUP_BY_SERVICEGROUP = "servicegroup"
+)
+
+// Ordered routing rules, the first matching rule wins
+type Router struct {
+	rules []Rule
+}
+
+// Compile the rules into a router
+func NewRouter(rules []Rule) (*Router, error) {
+	for i := range rules {
+		err := rules[i].compile()
+		if err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rules[i].Name, err)
+		}
+	}
+
+	return &Router{rules: rules}, nil
+}
+
+// Load a router from a JSON file holding an array of rules
+func LoadRouter(path string) (*Router, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	rules := []Rule{}
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&rules)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", path, err)
+	}
+
+	return NewRouter(rules)
+}
+
+func (r *Rule) compile() error {
+	var err error
+
+	if r.Match.Host != "" {
+		r.Match.host, err = regexp.Compile("^(?:" + r.Match.Host + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid host pattern: %w", err)
+		}
+	}
+
+	if r.Match.Service != "" {
+		r.Match.service, err = regexp.Compile("^(?:" + r.Match.Service + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid service pattern: %w", err)
+		}
+	}
+
+	if r.Match.Content != "" {
+		r.Match.content, err = regexp.Compile(r.Match.Content)
+		if err != nil {
+			return fmt.Errorf("invalid content pattern: %w", err)
+		}
+	}
+
+	err = r.Templates.compile()
+	if err != nil {
+		return err
+	}
+
+	if r.GroupWindowSeconds < 0 {
+		return fmt.Errorf("groupWindowSeconds must not be negative")
+	}
+
+	if r.GraceSeconds < 0 {
+		return fmt.Errorf("graceSeconds must not be negative")
+	}
+
+	if r.GroupWindowSeconds > 0 {
+		switch r.GroupBy {
+		case GROUP_BY_RULE, GROUP_BY_SITE, GROUP_BY_HOST, GROUP_BY_SERVICE, GROUP_BY_HOSTGROUP, GROUP_BY_SERVICEGROUP:
+		default:
+			return fmt.Errorf("groupBy must be one of: %s %s %s %s %s %s", GROUP_BY_RULE, GROUP_BY_SITE, GROUP_BY_HOST, GROUP_BY_SERVICE, GROUP_BY_HOSTGROUP, GROUP_BY_SERVICEGROUP)
+		}
+	}
+
+	for state := range r.States {
+		if !IsStateName(state) {
+			return fmt.Errorf("unknown Nagios state %q", state)
+		}
+	}
+
+	switch r.Urgency {
+	case "", incidents.URGENCY_CRITICAL, incidents.URGENCY_ERROR, incidents.URGENCY_WARNING, incidents.URGENCY_INFO:
+	default:
+		return fmt.Errorf("urgency must be one of: %s %s %s %s", incidents.URGENCY_CRITICAL, incidents.URGENCY_ERROR, incidents.URGENCY_WARNING, incidents.URGENCY_INFO)
+	}
+
+	return nil
+}
+
+// Whether the match matches the event
+func (m *Match) Matches(event models.EventItem) bool {
+	if len(m.Sites) > 0 && !slices.Contains(m.Sites, event.NagiosSiteName) {
+		return false
+	}
+
+	if m.host != nil && !m.host.MatchString(event.NagiosProblemHostname) {
+		return false
+	}
+
+	if m.service != nil && (event.NagiosProblemServiceName == "" || !m.service.MatchString(event.NagiosProblemServiceName)) {
+		return false
+	}
+
+	if len(m.HostGroups) > 0 && !containsAny(event.NagiosHostGroups, m.HostGroups) {
+		return false
+	}
+
+	if len(m.ServiceGroups) > 0 && !containsAny(event.NagiosServiceGroups, m.ServiceGroups) {
+		return false
+	}
+
+	if m.content != nil && !m.content.MatchString(event.NagiosProblemContent) {
+		return false
+	}
+
+	return true
+}
+
+func containsAny(values, wanted []string) bool {
+	for _, value := range values {
+		if slices.Contains(wanted, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// The first rule matching the event and its index, or nil and -1 when none match
+func (r *Router) Route(event models.EventItem) (*Rule, int) {
+	for i := range r.rules {
+		if r.rules[i].Match.Matches(event) {
+			return &r.rules[i], i
+		}
+	}
+	return nil, -1
+}
+
+// The rules, in order
+func (r *Router) Rules() []Rule {
+	return r.rules
+}
+
+// Whether any rule matches or groups problems by host or service groups
+func (r *Router) UsesGroups() bool {
+	for _, rule := range r.rules {
+		if len(rule.Match.HostGroups) > 0 || len(rule.Match.ServiceGroups) > 0 ||
+			(rule.GroupWindowSeconds > 0 && (rule.GroupBy == GROUP_BY_HOSTGROUP || rule.GroupBy == GROUP_BY_SERVICEGROUP)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the policy, team and urgency of the rule to an incident. The policy id of the incident is only replaced when it isn't set,
+// so the policy id sent with a notification overrides the rule.
+func (r *Rule) Apply(incident *incidents.Incident) {
+	if incident.PolicyId == "" {
+		incident.PolicyId = r.PolicyId
+	}
+	if r.Team != "" {
+		incident.Team = r.Team
+	}
+	if r.Urgency != "" {
+		incident.Urgency = r.Urgency
+	}
+}
+
+// Apply the channel settings of the rule to an incident for a problem in the Nagios state
+func (r *Rule) ApplyChannels(incident *incidents.Incident, state string) {
+	r.Channels.Apply(incident)
+	if state != "" {
+		r.States[state].Apply(incident)
+	}
+}
+
+// Key of the group the rule puts the event in, or empty when the rule doesn't group it
+func (r *Rule) GroupKey(event models.EventItem) string {
+	if r.GroupWindowSeconds <= 0 {
+		return ""
+	}
+
+	value := ""
+	switch r.GroupBy {
+	case GROUP_BY_RULE:
+		value = r.Name
+	case GROUP_BY_SITE:
+		value = event.NagiosSiteName
+	case GROUP_BY_HOST:
+		value = event.NagiosProblemHostname
+	case GROUP_BY_SERVICE:
+		value = event.NagiosProblemServiceName
+	case GROUP_BY_HOSTGROUP:
+		value = pickGroup(event.NagiosHostGroups, r.Match.HostGroups)
+	case GROUP_BY_SERVICEGROUP:
+		value = pickGroup(event.NagiosServiceGroups, r.Match.ServiceGroups)
+	}
+
+	if value == "" {
+		return ""
+	}
+
+	return r.Name + "|" + event.NagiosSiteName + "|" + r.GroupBy + "=" + value
+}
+
+// The first of groups the rule matches on, or the first of groups if the rule doesn't match on any
+func pickGroup(groups, matched []string) string {
+	for _, group := range groups {
+		if len(matched) == 0 || slices.Contains(matched, group) {
+			return group
+		}
+	}
+	return ""
+}
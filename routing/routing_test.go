@@ -0,0 +1,249 @@
+package routing
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func newTestRouter(t *testing.T, rules ...Rule) *Router {
+	t.Helper()
+
+	router, err := NewRouter(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return router
+}
+
+func hostEvent(site, host string) models.EventItem {
+	return models.EventItem{
+		NagiosSiteName:        site,
+		NagiosProblemType:     "HOST",
+		NagiosProblemHostname: host,
+	}
+}
+
+func serviceEvent(site, host, service string) models.EventItem {
+	return models.EventItem{
+		NagiosSiteName:           site,
+		NagiosProblemType:        "SERVICE",
+		NagiosProblemHostname:    host,
+		NagiosProblemServiceName: service,
+	}
+}
+
+func TestMatchMatches(t *testing.T) {
+	grouped := serviceEvent("site-a", "db-1", "postgres replication")
+	grouped.NagiosHostGroups = []string{"linux", "databases"}
+	grouped.NagiosServiceGroups = []string{"postgres"}
+	grouped.NagiosProblemContent = "CRITICAL - replication lag 300s"
+
+	tests := []struct {
+		name  string
+		match Match
+		event models.EventItem
+		want  bool
+	}{
+		{"empty match", Match{}, hostEvent("site-a", "web-1"), true},
+		{"site", Match{Sites: []string{"site-b", "site-a"}}, hostEvent("site-a", "web-1"), true},
+		{"other site", Match{Sites: []string{"site-b"}}, hostEvent("site-a", "web-1"), false},
+		{"host pattern", Match{Host: "web-[0-9]+"}, hostEvent("site-a", "web-12"), true},
+		{"host pattern is anchored at the start", Match{Host: "eb-1"}, hostEvent("site-a", "web-1"), false},
+		{"host pattern is anchored at the end", Match{Host: "web"}, hostEvent("site-a", "web-1"), false},
+		{"host pattern alternatives are anchored", Match{Host: "db|web"}, hostEvent("site-a", "web-1"), false},
+		{"host pattern alternatives", Match{Host: "db-1|web-1"}, hostEvent("site-a", "web-1"), true},
+		{"service pattern", Match{Service: "postgres.*"}, grouped, true},
+		{"service pattern is anchored", Match{Service: "replication"}, grouped, false},
+		{"service pattern never matches a host", Match{Service: ".*"}, hostEvent("site-a", "db-1"), false},
+		{"host group", Match{HostGroups: []string{"databases"}}, grouped, true},
+		{"any host group", Match{HostGroups: []string{"windows", "linux"}}, grouped, true},
+		{"other host group", Match{HostGroups: []string{"windows"}}, grouped, false},
+		{"host group without groups sent", Match{HostGroups: []string{"linux"}}, hostEvent("site-a", "db-1"), false},
+		{"service group", Match{ServiceGroups: []string{"postgres"}}, grouped, true},
+		{"other service group", Match{ServiceGroups: []string{"mysql"}}, grouped, false},
+		{"content matches anywhere", Match{Content: "replication lag"}, grouped, true},
+		{"other content", Match{Content: "disk"}, grouped, false},
+		{"all fields", Match{Sites: []string{"site-a"}, Host: "db-.*", Service: "postgres.*", HostGroups: []string{"databases"}, ServiceGroups: []string{"postgres"}, Content: "lag"}, grouped, true},
+		{"all fields but one", Match{Sites: []string{"site-a"}, Host: "db-.*", Service: "postgres.*", HostGroups: []string{"databases"}, ServiceGroups: []string{"postgres"}, Content: "disk"}, grouped, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router := newTestRouter(t, Rule{Name: "rule", Match: test.match})
+
+			got := router.rules[0].Match.Matches(test.event)
+			if got != test.want {
+				t.Errorf("Matches = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRouterRoute(t *testing.T) {
+	router := newTestRouter(t,
+		Rule{Name: "database replication", Match: Match{Host: "db-.*", Service: "postgres.*"}, PolicyId: "dba"},
+		Rule{Name: "databases", Match: Match{Host: "db-.*"}, PolicyId: "db"},
+		Rule{Name: "site b", Match: Match{Sites: []string{"site-b"}}, PolicyId: "site-b"},
+	)
+
+	tests := []struct {
+		name  string
+		event models.EventItem
+		want  string
+		index int
+	}{
+		{"first of several matching rules", serviceEvent("site-b", "db-1", "postgres replication"), "database replication", 0},
+		{"second rule", serviceEvent("site-b", "db-1", "disk /"), "databases", 1},
+		{"host problem skips service rule", hostEvent("site-a", "db-1"), "databases", 1},
+		{"last rule", hostEvent("site-b", "web-1"), "site b", 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rule, index := router.Route(test.event)
+			if rule == nil {
+				t.Fatal("expected a matching rule")
+			}
+			if rule.Name != test.want || index != test.index {
+				t.Errorf("matched rule %d %q, want %d %q", index, rule.Name, test.index, test.want)
+			}
+		})
+	}
+
+	rule, index := router.Route(hostEvent("site-a", "web-1"))
+	if rule != nil || index != -1 {
+		t.Errorf("expected no rule to match, got %d", index)
+	}
+
+	rule, index = (&Router{}).Route(hostEvent("site-a", "web-1"))
+	if rule != nil || index != -1 {
+		t.Errorf("expected no rule to match without rules, got %d", index)
+	}
+}
+
+func TestRuleApply(t *testing.T) {
+	rule := Rule{PolicyId: "rule-policy", Team: "ops", Urgency: incidents.URGENCY_WARNING}
+
+	incident := incidents.Incident{Team: "default-team", Urgency: incidents.URGENCY_CRITICAL}
+	rule.Apply(&incident)
+	if incident.PolicyId != "rule-policy" || incident.Team != "ops" || incident.Urgency != incidents.URGENCY_WARNING {
+		t.Errorf("unexpected incident after applying the rule: %+v", incident)
+	}
+
+	// the policy sent with the notification overrides the rule
+	incident = incidents.Incident{PolicyId: "notification-policy"}
+	rule.Apply(&incident)
+	if incident.PolicyId != "notification-policy" {
+		t.Errorf("policy id of the notification was replaced with %q", incident.PolicyId)
+	}
+
+	// unset settings of the rule leave the incident alone
+	incident = incidents.Incident{Team: "default-team", Urgency: incidents.URGENCY_CRITICAL}
+	(&Rule{}).Apply(&incident)
+	if incident.PolicyId != "" || incident.Team != "default-team" || incident.Urgency != incidents.URGENCY_CRITICAL {
+		t.Errorf("unexpected incident after applying an empty rule: %+v", incident)
+	}
+}
+
+func TestRuleGroupKey(t *testing.T) {
+	event := serviceEvent("site-a", "db-1", "postgres replication")
+	event.NagiosHostGroups = []string{"linux", "databases"}
+	event.NagiosServiceGroups = []string{"postgres", "replication"}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		event models.EventItem
+		want  string
+	}{
+		{"not grouped without window", Rule{Name: "db", GroupBy: GROUP_BY_HOST}, event, ""},
+		{"rule", Rule{Name: "db", GroupBy: GROUP_BY_RULE, GroupWindowSeconds: 60}, event, "db|site-a|rule=db"},
+		{"site", Rule{Name: "db", GroupBy: GROUP_BY_SITE, GroupWindowSeconds: 60}, event, "db|site-a|site=site-a"},
+		{"host", Rule{Name: "db", GroupBy: GROUP_BY_HOST, GroupWindowSeconds: 60}, event, "db|site-a|host=db-1"},
+		{"service", Rule{Name: "db", GroupBy: GROUP_BY_SERVICE, GroupWindowSeconds: 60}, event, "db|site-a|service=postgres replication"},
+		{"service of a host problem", Rule{Name: "db", GroupBy: GROUP_BY_SERVICE, GroupWindowSeconds: 60}, hostEvent("site-a", "db-1"), ""},
+		{"first host group", Rule{Name: "db", GroupBy: GROUP_BY_HOSTGROUP, GroupWindowSeconds: 60}, event, "db|site-a|hostgroup=linux"},
+		{"host group the rule matches", Rule{Name: "db", Match: Match{HostGroups: []string{"databases"}}, GroupBy: GROUP_BY_HOSTGROUP, GroupWindowSeconds: 60}, event, "db|site-a|hostgroup=databases"},
+		{"without host groups", Rule{Name: "db", GroupBy: GROUP_BY_HOSTGROUP, GroupWindowSeconds: 60}, hostEvent("site-a", "db-1"), ""},
+		{"first service group", Rule{Name: "db", GroupBy: GROUP_BY_SERVICEGROUP, GroupWindowSeconds: 60}, event, "db|site-a|servicegroup=postgres"},
+		{"service group the rule matches", Rule{Name: "db", Match: Match{ServiceGroups: []string{"replication"}}, GroupBy: GROUP_BY_SERVICEGROUP, GroupWindowSeconds: 60}, event, "db|site-a|servicegroup=replication"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := test.rule.GroupKey(test.event)
+			if got != test.want {
+				t.Errorf("GroupKey = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestNewRouterErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+	}{
+		{"invalid host pattern", Rule{Match: Match{Host: "db-("}}},
+		{"invalid service pattern", Rule{Match: Match{Service: "["}}},
+		{"invalid content pattern", Rule{Match: Match{Content: "("}}},
+		{"negative group window", Rule{GroupBy: GROUP_BY_HOST, GroupWindowSeconds: -1}},
+		{"unknown group by", Rule{GroupBy: "datacenter", GroupWindowSeconds: 60}},
+		{"negative grace", Rule{GraceSeconds: -1}},
+		{"unknown state", Rule{States: StateChannels{"BROKEN": {}}}},
+		{"unknown urgency", Rule{Urgency: "urgent"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewRouter([]Rule{test.rule})
+			if err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadRouter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{"valid", `[{"name": "web", "match": {"host": "web-.*", "hostGroups": ["web"]}, "policyId": "123", "groupBy": "host", "groupWindowSeconds": 60}]`, ""},
+		{"typo in rule", `[{"name": "web", "groupWindow": 60}]`, "unknown field"},
+		{"typo in match", `[{"name": "web", "match": {"hosts": "web-.*"}}]`, "unknown field"},
+		{"not an array", `{"name": "web"}`, "cannot unmarshal"},
+		{"invalid pattern", `[{"name": "web", "match": {"host": "web-("}}]`, "invalid host pattern"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routing.json")
+			err := os.WriteFile(path, []byte(test.rules), 0o600)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			router, err := LoadRouter(path)
+			if test.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(router.Rules()) != 1 || !router.UsesGroups() {
+					t.Errorf("unexpected router: %+v", router.Rules())
+				}
+				return
+			}
+
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", test.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+)
+
+// text/template templates for the name, summary and description of incidents.
+// Templates that aren't set leave the incident as it is.
+type Templates struct {
+	NameTemplate        string `json:"nameTemplate,omitempty"`
+	SummaryTemplate     string `json:"summaryTemplate,omitempty"`
+	DescriptionTemplate string `json:"descriptionTemplate,omitempty"`
+
+	name        *template.Template
+	summary     *template.Template
+	description *template.Template
+}
+
+// Compile templates for the name, summary and description, any of them may be empty
+func NewTemplates(name, summary, description string) (*Templates, error) {
+	t := &Templates{
+		NameTemplate:        name,
+		SummaryTemplate:     summary,
+		DescriptionTemplate: description,
+	}
+
+	err := t.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+func (t *Templates) compile() error {
+	var err error
+
+	t.name, err = parseTemplate("name", t.NameTemplate)
+	if err != nil {
+		return err
+	}
+
+	t.summary, err = parseTemplate("summary", t.SummaryTemplate)
+	if err != nil {
+		return err
+	}
+
+	t.description, err = parseTemplate("description", t.DescriptionTemplate)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(template.FuncMap{
+		// separator first, so lists can be piped: {{.Host.Groups | join ", "}}
+		"join": func(sep string, elems []string) string {
+			return strings.Join(elems, sep)
+		},
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+	}).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+
+	return tmpl, nil
+}
+
+// Whether any template is set
+func (t *Templates) IsSet() bool {
+	return t != nil && (t.name != nil || t.summary != nil || t.description != nil)
+}
+
+// Render the set templates with data into the incident. On error the incident is left as it is.
+func (t *Templates) Apply(incident *incidents.Incident, data interface{}) error {
+	if !t.IsSet() {
+		return nil
+	}
+
+	rendered := *incident
+
+	for _, field := range []struct {
+		tmpl  *template.Template
+		value *string
+	}{
+		{t.name, &rendered.Name},
+		{t.summary, &rendered.Summary},
+		{t.description, &rendered.Description},
+	} {
+		if field.tmpl == nil {
+			continue
+		}
+
+		var builder strings.Builder
+		err := field.tmpl.Execute(&builder, data)
+		if err != nil {
+			return err
+		}
+		*field.value = builder.String()
+	}
+
+	*incident = rendered
+	return nil
+}
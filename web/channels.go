@@ -0,0 +1,105 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/routing"
+)
+
+// Parse a comma separated list of on-call notification channels, "none" for no channels
+func parseChannels(value string) (routing.Channels, error) {
+	call, sms, email, push := false, false, false, false
+
+	for _, channel := range strings.Split(value, ",") {
+		switch strings.TrimSpace(channel) {
+		case "call":
+			call = true
+		case "sms":
+			sms = true
+		case "email":
+			email = true
+		case "push":
+			push = true
+		case "none", "":
+		default:
+			return routing.Channels{}, fmt.Errorf("unknown channel %q, must be one of: call sms email push none", channel)
+		}
+	}
+
+	return routing.Channels{Call: &call, Sms: &sms, Email: &email, Push: &push}, nil
+}
+
+// Load the on-call channel settings from INCIDENT_CHANNELS and INCIDENT_TEAM_WAIT_SECONDS,
+// and their per state variants like INCIDENT_CHANNELS_CRITICAL
+func loadIncidentChannels() routing.StateChannels {
+	stateChannels := routing.StateChannels{}
+
+	for _, state := range append([]string{""}, routing.StateNames...) {
+		suffix := ""
+		if state != "" {
+			suffix = "_" + state
+		}
+
+		channels := routing.Channels{}
+		var err error
+
+		if value, ok := os.LookupEnv("INCIDENT_CHANNELS" + suffix); ok {
+			channels, err = parseChannels(value)
+			if err != nil {
+				fmt.Println("invalid INCIDENT_CHANNELS"+suffix+":", err.Error())
+				os.Exit(1)
+			}
+		}
+
+		if value, ok := os.LookupEnv("INCIDENT_TEAM_WAIT_SECONDS" + suffix); ok {
+			teamWait, err := strconv.Atoi(value)
+			if err != nil || teamWait < 0 {
+				fmt.Println("INCIDENT_TEAM_WAIT_SECONDS" + suffix + " must be a positive number of seconds")
+				os.Exit(1)
+			}
+			channels.TeamWait = &teamWait
+		}
+
+		if channels.IsSet() {
+			stateChannels[state] = channels
+		}
+	}
+
+	return stateChannels
+}
+
+// Nagios state name of the problem of the event, e.g. "CRITICAL", or empty if it can't be determined.
+// Looked up in Nagios when the notification doesn't send it.
+func (wh *webHandler) getEventState(event models.EventItem) string {
+	if event.NagiosProblemState != "" {
+		return event.NagiosProblemState
+	}
+
+	state, _, err := wh.getNagiosProblemState(event)
+	if err != nil {
+		return ""
+	}
+	return nagios.StateName(event.NagiosProblemType, state)
+}
+
+// Apply the global on-call channel settings, then those of the routing rule, for the state of the problem
+func (wh *webHandler) applyIncidentChannels(incidentName string, event models.EventItem, rule *routing.Rule, incident *incidents.Incident) {
+	state := ""
+	if wh.IncidentChannels.NeedsState() || (rule != nil && rule.States.NeedsState()) {
+		state = wh.getEventState(event)
+		if state == "" {
+			fmt.Println("WARN Unknown Nagios state, using channel settings for all states: " + incidentName)
+		}
+	}
+
+	wh.IncidentChannels.Apply(incident, state)
+	if rule != nil {
+		rule.ApplyChannels(incident, state)
+	}
+}
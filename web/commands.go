@@ -0,0 +1,333 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/pkmollman/nagios-better-stack-connector/database"
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+// Administrative commands of the connector binary. They load the configuration and database the same way
+// the server does, and go through the same code paths for anything they change.
+
+var errServerRunning = errors.New("the server is running, stop it first")
+
+// Web handler for commands that only touch the database, so they work without the Nagios and incident configuration
+func newDatabaseOnlyWebHandler(dbClient database.DatabaseClient) *webHandler {
+	return NewWebHandler(dbClient, incidents.NewDestinationRegistry(), nagios.NewSiteRegistry())
+}
+
+// Find the stored event item with the id.
+// Caller must hold the database lock.
+func (wh *webHandler) getEventItem(id int64) (*models.EventItem, error) {
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.Id == id {
+			return &item, nil
+		}
+	}
+
+	return nil, fmt.Errorf("event item %d not found", id)
+}
+
+// Create and migrate the database tables, without starting the server
+func Migrate() error {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+
+	err := dbClient.Init()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Database is up to date")
+	return nil
+}
+
+// Backup the database to SQLITE_DB_BACKUP_DIR_PATH, without migrating it first
+func BackupDatabase() error {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	backupPath, err := dbClient.Backup()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Backed up database to", backupPath)
+	return nil
+}
+
+// Replace the database with a backup, and migrate it. Refuses to run while the server is running.
+func RestoreDatabase(backupPath string) error {
+	lock, err := lockDatabase()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+
+	err = func() error {
+		dbClient.Lock()
+		defer dbClient.Unlock()
+
+		previousPath, err := dbClient.Restore(backupPath)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Backed up the replaced database to", previousPath)
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	err = dbClient.Init()
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Restored database from", backupPath)
+	return nil
+}
+
+// List the stored event items, the problems with open incidents
+func ListEvents() error {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	initDatabaseClient(dbClient)
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	items, err := dbClient.GetAllEventItems()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSITE\tTYPE\tHOST\tSERVICE\tSTATE\tPOLICY\tINCIDENT")
+	for _, item := range items {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			item.Id,
+			item.NagiosSiteName,
+			item.NagiosProblemType,
+			item.NagiosProblemHostname,
+			item.NagiosProblemServiceName,
+			item.NagiosProblemState,
+			item.BetterStackPolicyId,
+			item.BetterStackIncidentId,
+		)
+	}
+	return w.Flush()
+}
+
+type eventDetails struct {
+	Event        models.EventItem         `json:"event"`
+	Destinations []models.DestinationItem `json:"destinations"`
+	Correlated   []models.CorrelationItem `json:"correlated"`
+	GroupMembers []models.GroupMemberItem `json:"groupMembers"`
+}
+
+// Show a stored event item as JSON, with its incidents at each destination and the problems attached to it
+func ShowEvent(id int64) error {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	initDatabaseClient(dbClient)
+
+	wh := newDatabaseOnlyWebHandler(dbClient)
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	item, err := wh.getEventItem(id)
+	if err != nil {
+		return err
+	}
+
+	details := eventDetails{Event: *item, GroupMembers: []models.GroupMemberItem{}}
+
+	details.Destinations, err = wh.getDestinationItems(*item)
+	if err != nil {
+		return err
+	}
+
+	details.Correlated, err = wh.getCorrelatedChildren(*item)
+	if err != nil {
+		return err
+	}
+
+	members, err := dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.EventItemId == item.Id {
+			details.GroupMembers = append(details.GroupMembers, member)
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(details)
+}
+
+// Delete a stored event item without touching its incidents, for event items whose incidents were closed elsewhere.
+// Refuses to run while the server is running.
+func DeleteEvent(id int64) error {
+	lock, err := lockDatabase()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	initDatabaseClient(dbClient)
+
+	wh := newDatabaseOnlyWebHandler(dbClient)
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	item, err := wh.getEventItem(id)
+	if err != nil {
+		return err
+	}
+
+	incidentName := identifyEvent(item)
+	return wh.deleteEventItem(incidentName, *item)
+}
+
+// Resolve the incidents of a stored event item at all its destinations and delete the event item,
+// like a RECOVERY notification. Problems attached or grouped to it that persist get their own incident.
+// Refuses to run while the server is running.
+func ResolveIncident(id int64, contactEmail string) error {
+	lock, err := lockDatabase()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	wh := loadWebHandler(dbClient)
+	initDatabaseClient(dbClient)
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	item, err := wh.getEventItem(id)
+	if err != nil {
+		return err
+	}
+
+	incidentName := identifyEvent(item)
+	members, err := wh.getGroupMembers(*item)
+	if err != nil {
+		return err
+	}
+
+	children, err := wh.recoverEventItem(incidentName, *item, contactEmail)
+	if err == nil {
+		wh.recordDecision(*item, item.BetterStackIncidentId, DECISION_RESOLVED, "resolved from the command line")
+	}
+
+	// the event item is gone even when resolving failed at a destination, attached and grouped problems that persist get their own incident
+	wh.releaseCorrelatedChildren(incidentName, children)
+	wh.releaseGroupMembers(incidentName, *item, members)
+	return err
+}
+
+// Acknowledge the incidents of a stored event item at all its destinations, optionally commenting on them.
+// Refuses to run while the server is running.
+func AcknowledgeIncident(id int64, contactEmail, comment string) error {
+	lock, err := lockDatabase()
+	if err != nil {
+		return err
+	}
+	defer lock.Close()
+
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	wh := loadWebHandler(dbClient)
+	initDatabaseClient(dbClient)
+
+	dbClient.Lock()
+	defer dbClient.Unlock()
+
+	item, err := wh.getEventItem(id)
+	if err != nil {
+		return err
+	}
+
+	incidentName := identifyEvent(item)
+	err = wh.acknowledgeIncidents(incidentName, *item, contactEmail)
+	if err != nil {
+		return err
+	}
+	fmt.Println("INFO Acknowledged incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+
+	if comment != "" {
+		err = wh.commentIncidents(incidentName, *item, comment)
+		if err != nil {
+			return err
+		}
+		fmt.Println("INFO Added comment to incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+	}
+
+	return nil
+}
+
+// Load the whole configuration like the server does, without touching the database, Nagios or the incident providers.
+// Invalid configuration exits like it does for the server.
+func CheckConfig() {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	backupFrequency := loadBackupFrequency()
+	wh := loadWebHandler(dbClient)
+
+	fmt.Println("Nagios sites:", wh.nagiosSites.Names())
+	fmt.Println("Incident destinations:", wh.destinations.Names(), "default:", wh.destinations.Defaults())
+	fmt.Println("Routing rules:", len(wh.Router.Rules()))
+	fmt.Println("Backup every", backupFrequency)
+	fmt.Println("Configuration is valid")
+}
+
+// Run the health checks of the server once, and print the result. Returns whether everything is healthy.
+func CheckHealth() (bool, error) {
+	dbClient := loadDatabaseClient()
+	defer dbClient.Shutdown()
+	wh := loadWebHandler(dbClient)
+	initDatabaseClient(dbClient)
+
+	format_template, err := template.New("status").Parse(healthTextTemplate)
+	if err != nil {
+		return false, err
+	}
+
+	wh.updateHealthStatus()
+
+	err = format_template.Execute(os.Stdout, wh.healthStatus)
+	if err != nil {
+		return false, err
+	}
+
+	return wh.healthStatus.Healthy(), nil
+}
@@ -0,0 +1,231 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/database"
+	"github.com/pkmollman/nagios-better-stack-connector/database/sqlitedb"
+	"github.com/pkmollman/nagios-better-stack-connector/routing"
+)
+
+// Create the database client from SQLITE_DB_PATH and SQLITE_DB_BACKUP_DIR_PATH, without touching the database
+func loadDatabaseClient() database.DatabaseClient {
+	sqliteDbPath := getEnvVarOrPanic("SQLITE_DB_PATH")
+	sqliteDbBackupDirPath := getEnvVarOrPanic("SQLITE_DB_BACKUP_DIR_PATH")
+
+	// should be able to swap this out for anything that implements the database.DatabaseClient interface
+	dbClient, err := sqlitedb.NewSQLiteClient(sqliteDbPath, sqliteDbBackupDirPath)
+	if err != nil {
+		fmt.Println("unable to create database client:", err.Error())
+		os.Exit(1)
+	}
+
+	return dbClient
+}
+
+// Take the lock of the database at SQLITE_DB_PATH, so commands changing it can't run while the server does
+func lockDatabase() (*os.File, error) {
+	return lockDatabaseFile(getEnvVarOrPanic("SQLITE_DB_PATH"))
+}
+
+// Create the tables of the database, and migrate the tables of older versions
+func initDatabaseClient(dbClient database.DatabaseClient) {
+	err := dbClient.Init()
+	if err != nil {
+		fmt.Println("unable to initialize database client:", err.Error())
+		os.Exit(1)
+	}
+}
+
+// How often to backup the database, from SQLITE_DB_BACKUP_FREQUENCY_MINUTES
+func loadBackupFrequency() time.Duration {
+	sqliteDbBackupFrequencyMinutesString := getEnvVarOrPanic("SQLITE_DB_BACKUP_FREQUENCY_MINUTES")
+
+	// convert string to int
+	sqliteDbBackupFrequencyMinutes, err := strconv.Atoi(sqliteDbBackupFrequencyMinutesString)
+	if err != nil {
+		fmt.Println("unable to convert SQLITE_DB_BACKUP_FREQUENCY_MINUTES to int:", err)
+		os.Exit(1)
+	}
+
+	return time.Minute * time.Duration(sqliteDbBackupFrequencyMinutes)
+}
+
+// Create the web handler with the incident destinations, Nagios sites and the settings from the environment
+func loadWebHandler(dbClient database.DatabaseClient) *webHandler {
+	// Incidents
+	// BETTER_STACK_COMMENT_INTERVAL_SECONDS is the name from before other incident providers were supported
+	incidentCommentIntervalSecondsString := getEnvVarOrDefault("INCIDENT_COMMENT_INTERVAL_SECONDS", getEnvVarOrDefault("BETTER_STACK_COMMENT_INTERVAL_SECONDS", "300"))
+
+	incidentCommentIntervalSeconds, err := strconv.Atoi(incidentCommentIntervalSecondsString)
+	if err != nil {
+		fmt.Println("unable to convert INCIDENT_COMMENT_INTERVAL_SECONDS to int:", err)
+		os.Exit(1)
+	}
+
+	incidentRecurrencePolicy := getEnvVarOrDefault("INCIDENT_RECURRENCE_POLICY", RECURRENCE_POLICY_NONE)
+
+	if incidentRecurrencePolicy == "reopen" {
+		fmt.Println("INCIDENT_RECURRENCE_POLICY reopen is not supported, the incident providers can't reopen resolved incidents, use", RECURRENCE_POLICY_REFERENCE)
+		os.Exit(1)
+	}
+
+	if incidentRecurrencePolicy != RECURRENCE_POLICY_NONE && incidentRecurrencePolicy != RECURRENCE_POLICY_REFERENCE {
+		fmt.Println("INCIDENT_RECURRENCE_POLICY must be one of:", RECURRENCE_POLICY_NONE, RECURRENCE_POLICY_REFERENCE)
+		os.Exit(1)
+	}
+
+	incidentRecurrenceWindowSeconds, err := strconv.Atoi(getEnvVarOrDefault("INCIDENT_RECURRENCE_WINDOW_SECONDS", "3600"))
+	if err != nil {
+		fmt.Println("unable to convert INCIDENT_RECURRENCE_WINDOW_SECONDS to int:", err)
+		os.Exit(1)
+	}
+
+	// optional bearer token for the endpoints that change Nagios or connector state, they are disabled without it
+	adminApiToken := getEnvVarOrDefault("ADMIN_API_TOKEN", "")
+
+	// Nagios
+	// optional bearer token notification clients must send
+	nagiosEventToken := getEnvVarOrDefault("NAGIOS_EVENT_TOKEN", "")
+
+	nagiosDowntimePolicy := getEnvVarOrDefault("NAGIOS_DOWNTIME_POLICY", DOWNTIME_POLICY_COMMENT)
+
+	if nagiosDowntimePolicy != DOWNTIME_POLICY_COMMENT && nagiosDowntimePolicy != DOWNTIME_POLICY_RESOLVE {
+		fmt.Println("NAGIOS_DOWNTIME_POLICY must be one of:", DOWNTIME_POLICY_COMMENT, DOWNTIME_POLICY_RESOLVE)
+		os.Exit(1)
+	}
+
+	nagiosFlappingPolicy := getEnvVarOrDefault("NAGIOS_FLAPPING_POLICY", FLAPPING_POLICY_IGNORE)
+
+	if nagiosFlappingPolicy != FLAPPING_POLICY_IGNORE &&
+		nagiosFlappingPolicy != FLAPPING_POLICY_INCIDENT &&
+		nagiosFlappingPolicy != FLAPPING_POLICY_HOLD &&
+		nagiosFlappingPolicy != FLAPPING_POLICY_SUPPRESS {
+		fmt.Println("NAGIOS_FLAPPING_POLICY must be one of:", FLAPPING_POLICY_IGNORE, FLAPPING_POLICY_INCIDENT, FLAPPING_POLICY_HOLD, FLAPPING_POLICY_SUPPRESS)
+		os.Exit(1)
+	}
+
+	nagiosPageStates := []string{}
+	for _, state := range strings.Split(getEnvVarOrDefault("NAGIOS_PAGE_STATES", ""), ",") {
+		state = strings.TrimSpace(state)
+		if state != "" {
+			nagiosPageStates = append(nagiosPageStates, strings.ToUpper(state))
+		}
+	}
+
+	nagiosPageSoftStates, err := strconv.ParseBool(getEnvVarOrDefault("NAGIOS_PAGE_SOFT_STATES", "true"))
+	if err != nil {
+		fmt.Println("unable to convert NAGIOS_PAGE_SOFT_STATES to bool:", err)
+		os.Exit(1)
+	}
+
+	nagiosEscalationPolicy := getEnvVarOrDefault("NAGIOS_ESCALATION_POLICY", ESCALATION_POLICY_RECREATE)
+	nagiosDeescalationPolicy := getEnvVarOrDefault("NAGIOS_DEESCALATION_POLICY", ESCALATION_POLICY_COMMENT)
+
+	for name, policy := range map[string]string{"NAGIOS_ESCALATION_POLICY": nagiosEscalationPolicy, "NAGIOS_DEESCALATION_POLICY": nagiosDeescalationPolicy} {
+		if policy != ESCALATION_POLICY_COMMENT && policy != ESCALATION_POLICY_RECREATE {
+			fmt.Println(name+" must be one of:", ESCALATION_POLICY_COMMENT, ESCALATION_POLICY_RECREATE)
+			os.Exit(1)
+		}
+	}
+
+	nagiosCorrelateServices, err := strconv.ParseBool(getEnvVarOrDefault("NAGIOS_CORRELATE_SERVICES", "true"))
+	if err != nil {
+		fmt.Println("unable to convert NAGIOS_CORRELATE_SERVICES to bool:", err)
+		os.Exit(1)
+	}
+
+	nagiosCorrelateParents, err := strconv.ParseBool(getEnvVarOrDefault("NAGIOS_CORRELATE_PARENTS", "true"))
+	if err != nil {
+		fmt.Println("unable to convert NAGIOS_CORRELATE_PARENTS to bool:", err)
+		os.Exit(1)
+	}
+
+	// Routing
+	router := &routing.Router{}
+	routingRulesPath := getEnvVarOrDefault("ROUTING_RULES_PATH", "")
+	if routingRulesPath != "" {
+		router, err = routing.LoadRouter(routingRulesPath)
+		if err != nil {
+			fmt.Println("unable to load routing rules:", err.Error())
+			os.Exit(1)
+		}
+		fmt.Println("Loaded", len(router.Rules()), "routing rule(s) from", routingRulesPath)
+	}
+
+	incidentTemplates, err := routing.NewTemplates(
+		getEnvVarOrDefault("INCIDENT_NAME_TEMPLATE", ""),
+		getEnvVarOrDefault("INCIDENT_SUMMARY_TEMPLATE", ""),
+		getEnvVarOrDefault("INCIDENT_DESCRIPTION_TEMPLATE", ""),
+	)
+	if err != nil {
+		fmt.Println("unable to parse incident templates:", err.Error())
+		os.Exit(1)
+	}
+
+	incidentChannels := loadIncidentChannels()
+
+	// Rate limits
+	rateLimitWindowSeconds, err := strconv.Atoi(getEnvVarOrDefault("RATE_LIMIT_WINDOW_SECONDS", "60"))
+	if err != nil || rateLimitWindowSeconds < 1 {
+		fmt.Println("RATE_LIMIT_WINDOW_SECONDS must be a number of seconds of at least 1")
+		os.Exit(1)
+	}
+
+	rateLimits := map[string]int{}
+	for _, name := range []string{"RATE_LIMIT_INCIDENTS", "RATE_LIMIT_SITE_INCIDENTS", "RATE_LIMIT_POLICY_INCIDENTS"} {
+		rateLimits[name], err = strconv.Atoi(getEnvVarOrDefault(name, "0"))
+		if err != nil || rateLimits[name] < 0 {
+			fmt.Println(name, "must be a number of incidents, or 0 for no limit")
+			os.Exit(1)
+		}
+	}
+
+	rateLimitStormPolicyId := getEnvVarOrDefault("RATE_LIMIT_STORM_POLICY_ID", "")
+
+	// create incident destinations
+	destinations := loadIncidentDestinationRegistry()
+
+	// routing rules can only open incidents at configured destinations
+	for _, rule := range router.Rules() {
+		for _, destinationName := range rule.Destinations {
+			_, err = destinations.Get(destinationName)
+			if err != nil {
+				fmt.Println("invalid routing rule", rule.Name+":", err.Error())
+				os.Exit(1)
+			}
+		}
+	}
+
+	// create nagios clients
+	nagiosSites := loadNagiosSiteRegistry()
+
+	webHandler := NewWebHandler(dbClient, destinations, nagiosSites)
+	webHandler.IncidentCommentInterval = time.Second * time.Duration(incidentCommentIntervalSeconds)
+	webHandler.NagiosEventToken = nagiosEventToken
+	webHandler.AdminApiToken = adminApiToken
+	webHandler.NagiosDowntimePolicy = nagiosDowntimePolicy
+	webHandler.NagiosFlappingPolicy = nagiosFlappingPolicy
+	webHandler.NagiosPageStates = nagiosPageStates
+	webHandler.NagiosPageSoftStates = nagiosPageSoftStates
+	webHandler.NagiosEscalationPolicy = nagiosEscalationPolicy
+	webHandler.NagiosDeescalationPolicy = nagiosDeescalationPolicy
+	webHandler.NagiosCorrelateServices = nagiosCorrelateServices
+	webHandler.NagiosCorrelateParents = nagiosCorrelateParents
+	webHandler.Router = router
+	webHandler.IncidentTemplates = incidentTemplates
+	webHandler.IncidentChannels = incidentChannels
+	webHandler.IncidentRecurrencePolicy = incidentRecurrencePolicy
+	webHandler.IncidentRecurrenceWindow = time.Second * time.Duration(incidentRecurrenceWindowSeconds)
+	webHandler.RateLimiter.Window = time.Second * time.Duration(rateLimitWindowSeconds)
+	webHandler.RateLimiter.Incidents = rateLimits["RATE_LIMIT_INCIDENTS"]
+	webHandler.RateLimiter.SiteIncidents = rateLimits["RATE_LIMIT_SITE_INCIDENTS"]
+	webHandler.RateLimiter.PolicyIncidents = rateLimits["RATE_LIMIT_POLICY_INCIDENTS"]
+	webHandler.RateLimiter.StormPolicyId = rateLimitStormPolicyId
+
+	return webHandler
+}
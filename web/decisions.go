@@ -0,0 +1,44 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	DECISION_SUPPRESSED   = "SUPPRESSED"
+	DECISION_RESOLVED     = "RESOLVED"
+	DECISION_COMMENTED    = "COMMENTED"
+	DECISION_RECREATED    = "RECREATED"
+	DECISION_CREATED      = "CREATED"
+	DECISION_HELD         = "HELD"
+	DECISION_IGNORED      = "IGNORED"
+	DECISION_ESCALATED    = "ESCALATED"
+	DECISION_DEESCALATED  = "DEESCALATED"
+	DECISION_CORRELATED   = "CORRELATED"
+	DECISION_GROUPED      = "GROUPED"
+	DECISION_RATE_LIMITED = "RATE_LIMITED"
+	DECISION_PENDING      = "PENDING"
+	DECISION_FAILED       = "FAILED"
+)
+
+// Record a decision made about an incoming notification, so it can be reviewed later via /api/decisions.
+// Caller must hold the database lock.
+func (wh *webHandler) recordDecision(event models.EventItem, incidentId, decision, reason string) {
+	_, err := wh.dbClient.CreateDecisionItem(models.DecisionItem{
+		NagiosSiteName:                event.NagiosSiteName,
+		NagiosProblemType:             event.NagiosProblemType,
+		NagiosProblemHostname:         event.NagiosProblemHostname,
+		NagiosProblemServiceName:      event.NagiosProblemServiceName,
+		NagiosProblemNotificationType: event.NagiosProblemNotificationType,
+		BetterStackIncidentId:         incidentId,
+		Decision:                      decision,
+		Reason:                        reason,
+		CreatedAt:                     time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Println("ERROR Failed to record decision " + decision + " (" + reason + "): " + err.Error())
+	}
+}
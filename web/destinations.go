@@ -0,0 +1,268 @@
+package web
+
+import (
+	"fmt"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Names of the destinations to open incidents at for the event
+func (wh *webHandler) eventDestinations(event models.EventItem) []string {
+	if len(event.IncidentDestinations) > 0 {
+		return event.IncidentDestinations
+	}
+	return wh.destinations.Defaults()
+}
+
+// Check the destinations named by the event exist
+func (wh *webHandler) validateEventDestinations(event models.EventItem) error {
+	for _, destinationName := range event.IncidentDestinations {
+		_, err := wh.destinations.Get(destinationName)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Open an incident at every destination of the event. Destinations that fail are logged and skipped,
+// an error is only returned when no incident could be opened at all.
+func (wh *webHandler) openDestinationIncidents(incidentName string, event models.EventItem, incident incidents.Incident) ([]models.DestinationItem, error) {
+	opened := []models.DestinationItem{}
+	var lastErr error
+
+	for _, destinationName := range wh.eventDestinations(event) {
+		destination, err := wh.destinations.Get(destinationName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		destinationIncident := incident
+		if destination.PolicyId != "" {
+			destinationIncident.PolicyId = destination.PolicyId
+		}
+
+		incidentId, err := destination.Provider.CreateIncident(destinationIncident)
+		if err != nil {
+			fmt.Println("ERROR Failed to create incident at " + destinationName + ": " + incidentName + " " + err.Error())
+			lastErr = err
+			continue
+		}
+
+		opened = append(opened, models.DestinationItem{
+			Destination: destinationName,
+			Provider:    destination.Provider.Name(),
+			PolicyId:    destinationIncident.PolicyId,
+			IncidentId:  incidentId,
+			Status:      incidents.STATUS_TRIGGERED,
+		})
+	}
+
+	if len(opened) == 0 {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no incident destinations configured")
+		}
+		return nil, lastErr
+	}
+
+	return opened, nil
+}
+
+// Destination items of the event item. Event items stored before destinations were tracked
+// get one for the first default destination, with Id 0 since it isn't stored.
+// Caller must hold the database lock.
+func (wh *webHandler) getDestinationItems(item models.EventItem) ([]models.DestinationItem, error) {
+	all, err := wh.dbClient.GetAllDestinationItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all destination items: " + err.Error())
+		return nil, err
+	}
+
+	destinationItems := []models.DestinationItem{}
+	for _, destinationItem := range all {
+		if destinationItem.EventItemId == item.Id {
+			destinationItems = append(destinationItems, destinationItem)
+		}
+	}
+
+	if len(destinationItems) == 0 && len(wh.destinations.Defaults()) > 0 {
+		destinationName := wh.destinations.Defaults()[0]
+		destination, _ := wh.destinations.Get(destinationName)
+		destinationItems = append(destinationItems, models.DestinationItem{
+			EventItemId: item.Id,
+			Destination: destinationName,
+			Provider:    destination.Provider.Name(),
+			PolicyId:    item.BetterStackPolicyId,
+			IncidentId:  item.BetterStackIncidentId,
+			Status:      incidents.STATUS_TRIGGERED,
+		})
+	}
+
+	return destinationItems, nil
+}
+
+// Run action against the incident at every destination of the event item, and set the status of the
+// destination items it succeeded for when status isn't empty. Returns the last error encountered.
+// Caller must hold the database lock.
+func (wh *webHandler) forEachDestination(incidentName string, item models.EventItem, status string, action func(incidents.IncidentProvider, incidents.IncidentRef) error) error {
+	destinationItems, err := wh.getDestinationItems(item)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, destinationItem := range destinationItems {
+		destination, err := wh.destinations.Get(destinationItem.Destination)
+		if err != nil {
+			fmt.Println("WARN Skipping incident at removed destination " + destinationItem.Destination + ": " + incidentName)
+			continue
+		}
+
+		err = action(destination.Provider, incidents.IncidentRef{
+			Id:       destinationItem.IncidentId,
+			PolicyId: destinationItem.PolicyId,
+		})
+		if err != nil {
+			fmt.Println("WARN Failed to update incident at " + destinationItem.Destination + ": " + incidentName + " incident ID " + destinationItem.IncidentId + " " + err.Error())
+			lastErr = err
+			continue
+		}
+
+		if status != "" && destinationItem.Id != 0 {
+			destinationItem.Status = status
+			_, err = wh.dbClient.UpdateDestinationItem(destinationItem)
+			if err != nil {
+				fmt.Println(fmt.Sprintf("ERROR Failed to update destination item: %s ID %d %s", incidentName, destinationItem.Id, err.Error()))
+			}
+		}
+	}
+
+	return lastErr
+}
+
+// Look up the status of the incidents of the event item at their providers, and store the ones that changed.
+// Returns whether all of them were resolved, e.g. by a responder while no notifications came in.
+// Incidents whose status can't be looked up, like chat messages, count as open.
+// Caller must hold the database lock.
+func (wh *webHandler) incidentsResolved(incidentName string, item models.EventItem) bool {
+	destinationItems, err := wh.getDestinationItems(item)
+	if err != nil || len(destinationItems) == 0 {
+		return false
+	}
+
+	resolved := true
+	for _, destinationItem := range destinationItems {
+		destination, err := wh.destinations.Get(destinationItem.Destination)
+		if err != nil {
+			resolved = false
+			continue
+		}
+
+		status, err := destination.Provider.GetIncidentStatus(incidents.IncidentRef{
+			Id:       destinationItem.IncidentId,
+			PolicyId: destinationItem.PolicyId,
+		})
+		if err != nil {
+			fmt.Println("WARN Failed to get status of incident at " + destinationItem.Destination + ": " + incidentName + " incident ID " + destinationItem.IncidentId + " " + err.Error())
+			resolved = false
+			continue
+		}
+
+		if status != destinationItem.Status && destinationItem.Id != 0 {
+			destinationItem.Status = status
+			_, err = wh.dbClient.UpdateDestinationItem(destinationItem)
+			if err != nil {
+				fmt.Println(fmt.Sprintf("ERROR Failed to update destination item: %s ID %d %s", incidentName, destinationItem.Id, err.Error()))
+			}
+		}
+
+		if status != incidents.STATUS_RESOLVED {
+			resolved = false
+		}
+	}
+
+	return resolved
+}
+
+// Acknowledge the incidents of the event item at all its destinations
+func (wh *webHandler) acknowledgeIncidents(incidentName string, item models.EventItem, contactEmail string) error {
+	return wh.forEachDestination(incidentName, item, incidents.STATUS_ACKNOWLEDGED, func(provider incidents.IncidentProvider, ref incidents.IncidentRef) error {
+		return provider.AcknowledgeIncident(ref, contactEmail)
+	})
+}
+
+// Resolve the incidents of the event item at all its destinations
+func (wh *webHandler) resolveIncidents(incidentName string, item models.EventItem, contactEmail string) error {
+	return wh.forEachDestination(incidentName, item, incidents.STATUS_RESOLVED, func(provider incidents.IncidentProvider, ref incidents.IncidentRef) error {
+		return provider.ResolveIncident(ref, contactEmail)
+	})
+}
+
+// Comment on the incidents of the event item at all its destinations
+func (wh *webHandler) commentIncidents(incidentName string, item models.EventItem, content string) error {
+	return wh.forEachDestination(incidentName, item, "", func(provider incidents.IncidentProvider, ref incidents.IncidentRef) error {
+		return provider.AddIncidentComment(ref, content)
+	})
+}
+
+// Delete the event item, its destination items, the problems attached to it and its group members.
+// Caller must hold the database lock.
+func (wh *webHandler) deleteEventItem(incidentName string, item models.EventItem) error {
+	children, err := wh.getCorrelatedChildren(item)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range children {
+		_, err = wh.dbClient.DeleteCorrelationItem(child.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete correlation item: %s ID %d %s", incidentName, child.Id, err.Error()))
+			return err
+		}
+	}
+
+	members, err := wh.dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all group member items: " + err.Error())
+		return err
+	}
+
+	for _, member := range members {
+		if member.EventItemId != item.Id {
+			continue
+		}
+		_, err = wh.dbClient.DeleteGroupMemberItem(member.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete group member item: %s ID %d %s", incidentName, member.Id, err.Error()))
+			return err
+		}
+	}
+
+	destinationItems, err := wh.dbClient.GetAllDestinationItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all destination items: " + err.Error())
+		return err
+	}
+
+	for _, destinationItem := range destinationItems {
+		if destinationItem.EventItemId != item.Id {
+			continue
+		}
+		_, err = wh.dbClient.DeleteDestinationItem(destinationItem.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete destination item: %s ID %d %s", incidentName, destinationItem.Id, err.Error()))
+			return err
+		}
+	}
+
+	_, err = wh.dbClient.DeleteEventItem(item.Id)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to delete event item: %s ID %d %s", incidentName, item.Id, err.Error()))
+		return err
+	}
+	fmt.Println(fmt.Sprintf("INFO Deleted event item: %s ID %d", incidentName, item.Id))
+
+	return nil
+}
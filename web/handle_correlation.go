@@ -0,0 +1,201 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+// Find the open HOST incident of a host at the site of the event, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findOpenHostItem(event models.EventItem, hostname string) (*models.EventItem, error) {
+	hostEvent := event
+	hostEvent.NagiosProblemType = "HOST"
+	hostEvent.NagiosProblemHostname = hostname
+	hostEvent.NagiosProblemServiceName = ""
+
+	return wh.findOpenEventItem(hostEvent)
+}
+
+// Find the open host incident a PROBLEM should be attached to: for services the incident of their host,
+// for hosts the incident of a parent host. Returns nil when the problem needs its own incident.
+// Caller must hold the database lock.
+func (wh *webHandler) findCorrelationParent(event models.EventItem) (*models.EventItem, error) {
+	if event.NagiosProblemType == "SERVICE" && wh.NagiosCorrelateServices {
+		return wh.findOpenHostItem(event, event.NagiosProblemHostname)
+	}
+
+	if event.NagiosProblemType == "HOST" && wh.NagiosCorrelateParents {
+		nagiosClient, err := wh.nagiosSites.Get(event.NagiosSiteName)
+		if err != nil {
+			return nil, err
+		}
+
+		hostState, err := nagiosClient.GetHostState(event.NagiosProblemHostname)
+		if errors.Is(err, nagios.ErrStateUnsupported) {
+			return nil, nil
+		}
+		if err != nil {
+			// a failed lookup shouldn't keep the host from being paged
+			fmt.Println("WARN Failed to get parents of host: " + event.NagiosProblemHostname + " " + err.Error())
+			return nil, nil
+		}
+
+		for _, parent := range hostState.Parents {
+			parentItem, err := wh.findOpenHostItem(event, parent)
+			if err != nil {
+				return nil, err
+			}
+			if parentItem != nil {
+				return parentItem, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// Attach a PROBLEM to the open incident of its host or parent host instead of opening a new incident.
+// Returns whether the problem was attached.
+// Caller must hold the database lock.
+func (wh *webHandler) handleCorrelatedProblem(incidentName string, event models.EventItem) (bool, error) {
+	parentItem, err := wh.findCorrelationParent(event)
+	if err != nil || parentItem == nil {
+		return false, err
+	}
+
+	correlations, err := wh.dbClient.GetAllCorrelationItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all correlation items: " + err.Error())
+		return false, err
+	}
+
+	for _, correlation := range correlations {
+		if correlation.NagiosSiteName == event.NagiosSiteName &&
+			correlation.NagiosProblemType == event.NagiosProblemType &&
+			correlation.NagiosProblemHostname == event.NagiosProblemHostname &&
+			correlation.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			fmt.Println("INFO Ignoring superfluous nagios notification for correlated problem: \"" + incidentName + "\"")
+			return true, nil
+		}
+	}
+
+	_, err = wh.dbClient.CreateCorrelationItem(models.CorrelationItem{
+		ParentEventItemId:        parentItem.Id,
+		NagiosSiteName:           event.NagiosSiteName,
+		NagiosProblemId:          event.NagiosProblemId,
+		NagiosProblemType:        event.NagiosProblemType,
+		NagiosProblemHostname:    event.NagiosProblemHostname,
+		NagiosProblemServiceName: event.NagiosProblemServiceName,
+		NagiosProblemContent:     event.NagiosProblemContent,
+		BetterStackPolicyId:      event.BetterStackPolicyId,
+		CreatedAt:                time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Println("ERROR Failed to create correlation item: " + incidentName + " " + err.Error())
+		return false, err
+	}
+
+	reason := "host " + parentItem.NagiosProblemHostname + " has an open incident"
+	if event.NagiosProblemType == "HOST" {
+		reason = "parent host " + parentItem.NagiosProblemHostname + " has an open incident"
+	}
+
+	err = wh.commentIncidents(incidentName, *parentItem, "Also affected: "+incidentName+": "+event.NagiosProblemContent)
+	if err != nil {
+		fmt.Println("WARN Failed to comment on incident for correlated problem: " + incidentName + " incident ID " + parentItem.BetterStackIncidentId + " " + err.Error())
+	}
+
+	wh.recordDecision(event, parentItem.BetterStackIncidentId, DECISION_CORRELATED, reason)
+	fmt.Println("INFO Attached problem to incident of " + reason + ": " + incidentName)
+	return true, nil
+}
+
+// Handle the RECOVERY of a problem attached to a host incident. Returns whether the problem was attached.
+// Caller must hold the database lock.
+func (wh *webHandler) handleCorrelatedRecovery(incidentName string, event models.EventItem) (bool, error) {
+	correlations, err := wh.dbClient.GetAllCorrelationItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all correlation items: " + err.Error())
+		return false, err
+	}
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return false, err
+	}
+
+	attached := false
+	for _, correlation := range correlations {
+		if correlation.NagiosSiteName != event.NagiosSiteName ||
+			correlation.NagiosProblemType != event.NagiosProblemType ||
+			correlation.NagiosProblemHostname != event.NagiosProblemHostname ||
+			correlation.NagiosProblemServiceName != event.NagiosProblemServiceName {
+			continue
+		}
+		attached = true
+
+		_, err = wh.dbClient.DeleteCorrelationItem(correlation.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete correlation item: %s ID %d %s", incidentName, correlation.Id, err.Error()))
+			return true, err
+		}
+
+		for _, item := range items {
+			if item.Id == correlation.ParentEventItemId {
+				err = wh.commentIncidents(incidentName, item, "Recovered: "+incidentName+": "+event.NagiosProblemContent)
+				if err != nil {
+					fmt.Println("WARN Failed to comment on incident for correlated recovery: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+				}
+			}
+		}
+	}
+
+	return attached, nil
+}
+
+// Problems attached to the incident of the event item.
+// Caller must hold the database lock.
+func (wh *webHandler) getCorrelatedChildren(item models.EventItem) ([]models.CorrelationItem, error) {
+	correlations, err := wh.dbClient.GetAllCorrelationItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all correlation items: " + err.Error())
+		return nil, err
+	}
+
+	children := []models.CorrelationItem{}
+	for _, correlation := range correlations {
+		if correlation.ParentEventItemId == item.Id {
+			children = append(children, correlation)
+		}
+	}
+
+	return children, nil
+}
+
+// After the host incident they were attached to recovered, give problems that persist their own incident.
+// The correlation items are deleted along with the host event item.
+// Caller must hold the database lock.
+func (wh *webHandler) releaseCorrelatedChildren(parentName string, children []models.CorrelationItem) {
+	for _, child := range children {
+		childEvent := models.EventItem{
+			NagiosSiteName:                child.NagiosSiteName,
+			NagiosProblemId:               child.NagiosProblemId,
+			NagiosProblemHostname:         child.NagiosProblemHostname,
+			NagiosProblemServiceName:      child.NagiosProblemServiceName,
+			NagiosProblemContent:          child.NagiosProblemContent,
+			NagiosProblemNotificationType: "PROBLEM",
+			BetterStackPolicyId:           child.BetterStackPolicyId,
+		}
+		childName := identifyEvent(&childEvent)
+
+		err := wh.reconcileWithNagiosState(childName, childEvent, child.BetterStackPolicyId, "host incident "+parentName+" recovered")
+		if err != nil {
+			fmt.Println("ERROR Failed to reconcile correlated problem: " + childName + " " + err.Error())
+		}
+	}
+}
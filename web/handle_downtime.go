@@ -0,0 +1,277 @@
+package web
+
+import (
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	// resolve open incidents when their object enters downtime
+	DOWNTIME_POLICY_RESOLVE = "resolve"
+	// comment on open incidents when their object enters downtime, and leave them open
+	DOWNTIME_POLICY_COMMENT = "comment"
+)
+
+// A host downtime covers the host and all of its services, a service downtime only covers the service
+func downtimeCovers(downtime models.DowntimeItem, event models.EventItem) bool {
+	if downtime.NagiosSiteName != event.NagiosSiteName ||
+		downtime.NagiosProblemHostname != event.NagiosProblemHostname {
+		return false
+	}
+
+	if downtime.NagiosProblemType == "HOST" {
+		return true
+	}
+
+	return event.NagiosProblemType == "SERVICE" &&
+		downtime.NagiosProblemServiceName == event.NagiosProblemServiceName
+}
+
+// Same host/service, or the same host for host problems
+func sameObject(a, b models.EventItem) bool {
+	return a.NagiosSiteName == b.NagiosSiteName &&
+		a.NagiosProblemType == b.NagiosProblemType &&
+		a.NagiosProblemHostname == b.NagiosProblemHostname &&
+		a.NagiosProblemServiceName == b.NagiosProblemServiceName
+}
+
+// Downtime item for the same host/service as the event, rather than covering it
+func sameDowntimeObject(downtime models.DowntimeItem, event models.EventItem) bool {
+	return downtime.NagiosSiteName == event.NagiosSiteName &&
+		downtime.NagiosProblemType == event.NagiosProblemType &&
+		downtime.NagiosProblemHostname == event.NagiosProblemHostname &&
+		downtime.NagiosProblemServiceName == event.NagiosProblemServiceName
+}
+
+// Downtime items covering the host/service of the event.
+// Caller must hold the database lock.
+func (wh *webHandler) getCoveringDowntimes(event models.EventItem) ([]models.DowntimeItem, error) {
+	downtimes, err := wh.dbClient.GetAllDowntimeItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all downtime items: " + err.Error())
+		return nil, err
+	}
+
+	covering := []models.DowntimeItem{}
+	for _, downtime := range downtimes {
+		if downtimeCovers(downtime, event) {
+			covering = append(covering, downtime)
+		}
+	}
+
+	return covering, nil
+}
+
+// Caller must hold the database lock
+func (wh *webHandler) isInDowntime(event models.EventItem) bool {
+	downtimes, err := wh.getCoveringDowntimes(event)
+	return err == nil && len(downtimes) > 0
+}
+
+// Remember a problem suppressed or resolved during the downtimes, so it is brought in line with Nagios when they end.
+// Caller must hold the database lock.
+func (wh *webHandler) attachToDowntimes(incidentName string, event models.EventItem, downtimes []models.DowntimeItem) {
+	for _, downtime := range downtimes {
+		events := []models.EventItem{}
+		for _, attached := range downtime.Events {
+			if !sameObject(attached, event) {
+				events = append(events, attached)
+			}
+		}
+		downtime.Events = append(events, event)
+
+		_, err := wh.dbClient.UpdateDowntimeItem(downtime)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to update downtime item: %s ID %d %s", incidentName, downtime.Id, err.Error()))
+		}
+	}
+}
+
+// Suppress a PROBLEM for a host/service in scheduled downtime, it is reconciled with Nagios when the downtime ends.
+// Returns whether the problem was suppressed.
+// Caller must hold the database lock.
+func (wh *webHandler) handleDowntimeProblem(incidentName string, event models.EventItem) (bool, error) {
+	downtimes, err := wh.getCoveringDowntimes(event)
+	if err != nil || len(downtimes) == 0 {
+		return false, err
+	}
+
+	wh.attachToDowntimes(incidentName, event, downtimes)
+	wh.recordDecision(event, "", DECISION_SUPPRESSED, "object is in scheduled downtime")
+	fmt.Println("INFO Suppressing notification for incident in scheduled downtime: \"" + incidentName + "\"")
+	return true, nil
+}
+
+func (wh *webHandler) handleDowntimeStart(incidentName string, event models.EventItem) error {
+	downtimes, err := wh.dbClient.GetAllDowntimeItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all downtime items: " + err.Error())
+		return err
+	}
+
+	downtime := models.DowntimeItem{
+		NagiosSiteName:           event.NagiosSiteName,
+		NagiosProblemType:        event.NagiosProblemType,
+		NagiosProblemHostname:    event.NagiosProblemHostname,
+		NagiosProblemServiceName: event.NagiosProblemServiceName,
+		BetterStackPolicyId:      event.BetterStackPolicyId,
+		StartedAt:                time.Now().Unix(),
+	}
+
+	// overlapping downtimes each send DOWNTIMESTART and DOWNTIMEEND, track one item per downtime so the
+	// object stays in downtime until the last one ends
+	overlapping := slices.ContainsFunc(downtimes, func(existing models.DowntimeItem) bool {
+		return sameDowntimeObject(existing, event)
+	})
+
+	downtime.Id, err = wh.dbClient.CreateDowntimeItem(downtime)
+	if err != nil {
+		fmt.Println("ERROR Failed to create downtime item: " + incidentName + " " + err.Error())
+		return err
+	}
+	fmt.Println("INFO Tracking scheduled downtime: " + incidentName)
+
+	// open incidents were already handled when the first downtime started
+	if overlapping {
+		wh.recordDecision(event, "", DECISION_IGNORED, "scheduled downtime started, object is already in another downtime")
+		return nil
+	}
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return err
+	}
+
+	affected := 0
+	for _, item := range items {
+		if !downtimeCovers(downtime, item) {
+			continue
+		}
+		affected++
+
+		switch wh.NagiosDowntimePolicy {
+		case DOWNTIME_POLICY_RESOLVE:
+			err := wh.resolveEventItem(incidentName, item, event.InteractingUserEmail)
+			if err != nil {
+				continue
+			}
+			downtime.Events = append(downtime.Events, item)
+			wh.recordDecision(event, item.BetterStackIncidentId, DECISION_RESOLVED, "scheduled downtime started, downtime policy is resolve")
+		default:
+			err := wh.commentIncidents(incidentName, item, "Scheduled downtime started in Nagios for "+incidentName+", further notifications are suppressed until it ends.")
+			if err != nil {
+				fmt.Println("WARN Failed to comment on incident for downtime: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+				continue
+			}
+			wh.recordDecision(event, item.BetterStackIncidentId, DECISION_COMMENTED, "scheduled downtime started, downtime policy is comment")
+			fmt.Println("INFO Commented on incident for downtime: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+		}
+	}
+
+	if affected == 0 {
+		wh.recordDecision(event, "", DECISION_IGNORED, "scheduled downtime started, no open incident")
+	}
+
+	// resolved problems that persist get a new incident when the downtime ends
+	if len(downtime.Events) > 0 {
+		_, err = wh.dbClient.UpdateDowntimeItem(downtime)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to update downtime item: %s ID %d %s", incidentName, downtime.Id, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+// Stop tracking the downtime, and bring the host/service in line with its Nagios state. When a host downtime ends the
+// services of the host it suppressed or resolved, and those with open incidents, are brought in line as well.
+func (wh *webHandler) handleDowntimeEnd(incidentName string, event models.EventItem) error {
+	downtimes, err := wh.dbClient.GetAllDowntimeItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all downtime items: " + err.Error())
+		return err
+	}
+
+	// one downtime ended, the oldest of the overlapping ones tracked for the host/service
+	policyId := event.BetterStackPolicyId
+	attached := []models.EventItem{}
+	for _, downtime := range downtimes {
+		if !sameDowntimeObject(downtime, event) {
+			continue
+		}
+
+		if downtime.BetterStackPolicyId != "" {
+			policyId = downtime.BetterStackPolicyId
+		}
+		attached = append(attached, downtime.Events...)
+		_, err := wh.dbClient.DeleteDowntimeItem(downtime.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete downtime item: %s ID %d %s", incidentName, downtime.Id, err.Error()))
+			return err
+		}
+		break
+	}
+	fmt.Println("INFO Scheduled downtime ended: " + incidentName)
+
+	reconciled := []models.EventItem{}
+	if wh.isInDowntime(event) {
+		wh.recordDecision(event, "", DECISION_IGNORED, "scheduled downtime ended, object is still covered by another downtime")
+	} else {
+		reconciled = append(reconciled, event)
+		err = wh.reconcileWithNagiosState(incidentName, event, policyId, "scheduled downtime ended")
+		if err != nil {
+			return err
+		}
+	}
+
+	if event.NagiosProblemType == "HOST" {
+		items, err := wh.dbClient.GetAllEventItems()
+		if err != nil {
+			fmt.Println("ERROR Failed to get all event items: " + err.Error())
+			return err
+		}
+
+		ended := models.DowntimeItem{
+			NagiosSiteName:        event.NagiosSiteName,
+			NagiosProblemType:     event.NagiosProblemType,
+			NagiosProblemHostname: event.NagiosProblemHostname,
+		}
+		for _, item := range items {
+			if item.NagiosProblemType == "SERVICE" && downtimeCovers(ended, item) {
+				attached = append(attached, item)
+			}
+		}
+	}
+
+	for _, problem := range attached {
+		if slices.ContainsFunc(reconciled, func(done models.EventItem) bool { return sameObject(done, problem) }) {
+			continue
+		}
+		reconciled = append(reconciled, problem)
+
+		problem.NagiosProblemNotificationType = event.NagiosProblemNotificationType
+		problem.InteractingUserEmail = event.InteractingUserEmail
+		problemName := identifyEvent(&problem)
+
+		// the downtimes still covering the problem bring it in line when they end
+		covering, err := wh.getCoveringDowntimes(problem)
+		if err == nil && len(covering) > 0 {
+			wh.attachToDowntimes(problemName, problem, covering)
+			if !sameObject(problem, event) {
+				wh.recordDecision(problem, "", DECISION_IGNORED, "scheduled downtime ended, object is still covered by another downtime")
+			}
+			continue
+		}
+
+		err = wh.reconcileWithNagiosState(problemName, problem, problem.BetterStackPolicyId, "scheduled downtime of "+incidentName+" ended")
+		if err != nil {
+			fmt.Println("ERROR Failed to reconcile problem after downtime: " + problemName + " " + err.Error())
+		}
+	}
+
+	return nil
+}
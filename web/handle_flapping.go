@@ -0,0 +1,193 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	// ignore flapping notifications, PROBLEM/RECOVERY are handled as usual
+	FLAPPING_POLICY_IGNORE = "ignore"
+	// open a single "flapping" incident, and hold its resolution until flapping stops
+	FLAPPING_POLICY_INCIDENT = "incident"
+	// comment on an existing incident, and hold its resolution until flapping stops
+	FLAPPING_POLICY_HOLD = "hold"
+	// suppress paging entirely while flapping
+	FLAPPING_POLICY_SUPPRESS = "suppress"
+)
+
+// Get the flapping item for the host/service of the event, or nil if it is not flapping.
+// Caller must hold the database lock.
+func (wh *webHandler) getFlappingItem(event models.EventItem) (*models.FlappingItem, error) {
+	items, err := wh.dbClient.GetAllFlappingItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all flapping items: " + err.Error())
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.NagiosSiteName == event.NagiosSiteName &&
+			item.NagiosProblemType == event.NagiosProblemType &&
+			item.NagiosProblemHostname == event.NagiosProblemHostname &&
+			item.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			return &item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (wh *webHandler) handleFlappingStart(incidentName string, event models.EventItem) error {
+	if wh.NagiosFlappingPolicy == FLAPPING_POLICY_IGNORE {
+		wh.recordDecision(event, "", DECISION_IGNORED, "flapping started, flapping policy is ignore")
+		return nil
+	}
+
+	flapping, err := wh.getFlappingItem(event)
+	if err != nil {
+		return err
+	}
+
+	if flapping == nil {
+		_, err = wh.dbClient.CreateFlappingItem(models.FlappingItem{
+			NagiosSiteName:           event.NagiosSiteName,
+			NagiosProblemType:        event.NagiosProblemType,
+			NagiosProblemHostname:    event.NagiosProblemHostname,
+			NagiosProblemServiceName: event.NagiosProblemServiceName,
+			BetterStackPolicyId:      event.BetterStackPolicyId,
+			StartedAt:                time.Now().Unix(),
+		})
+		if err != nil {
+			fmt.Println("ERROR Failed to create flapping item: " + incidentName + " " + err.Error())
+			return err
+		}
+	}
+	fmt.Println("INFO Tracking flapping: " + incidentName)
+
+	openItem, err := wh.findOpenEventItem(event)
+	if err != nil {
+		return err
+	}
+
+	if openItem != nil {
+		comment := "Nagios detected flapping, resolution is held until flapping stops."
+		if wh.NagiosFlappingPolicy == FLAPPING_POLICY_SUPPRESS {
+			comment = "Nagios detected flapping, paging is suppressed until flapping stops."
+		}
+		err := wh.commentIncidents(incidentName, *openItem, comment)
+		if err != nil {
+			fmt.Println("WARN Failed to comment on incident for flapping: " + incidentName + " incident ID " + openItem.BetterStackIncidentId + " " + err.Error())
+		}
+		wh.recordDecision(event, openItem.BetterStackIncidentId, DECISION_COMMENTED, "flapping started, flapping policy is "+wh.NagiosFlappingPolicy)
+		return nil
+	}
+
+	switch wh.NagiosFlappingPolicy {
+	case FLAPPING_POLICY_INCIDENT:
+		paged, reason := wh.isPagedState(event)
+		if !paged {
+			wh.recordDecision(event, "", DECISION_IGNORED, "flapping started, "+reason)
+			return nil
+		}
+
+		flappingEvent := event
+		flappingEvent.NagiosProblemNotificationType = "PROBLEM"
+		flappingEvent.NagiosProblemContent = "Nagios detected flapping: " + event.NagiosProblemContent
+		incidentId, err := wh.createIncident(incidentName+" (flapping)", flappingEvent)
+		if err != nil {
+			return err
+		}
+		wh.recordDecision(event, incidentId, DECISION_CREATED, "flapping started, flapping policy is incident")
+	case FLAPPING_POLICY_SUPPRESS:
+		wh.recordDecision(event, "", DECISION_SUPPRESSED, "flapping started, flapping policy is suppress")
+	default:
+		wh.recordDecision(event, "", DECISION_IGNORED, "flapping started, no open incident to hold")
+	}
+
+	return nil
+}
+
+// Handle a PROBLEM notification for a flapping host/service, returns false if it should be handled as usual.
+// Caller must hold the database lock.
+func (wh *webHandler) handleFlappingProblem(incidentName string, event models.EventItem) (bool, error) {
+	if wh.NagiosFlappingPolicy == FLAPPING_POLICY_IGNORE {
+		return false, nil
+	}
+
+	flapping, err := wh.getFlappingItem(event)
+	if err != nil || flapping == nil {
+		return false, err
+	}
+
+	if wh.NagiosFlappingPolicy == FLAPPING_POLICY_SUPPRESS {
+		wh.recordDecision(event, "", DECISION_SUPPRESSED, "object is flapping, flapping policy is suppress")
+		fmt.Println("INFO Suppressing notification for flapping incident: \"" + incidentName + "\"")
+		return true, nil
+	}
+
+	openItem, err := wh.findOpenEventItem(event)
+	if err != nil || openItem == nil {
+		return false, err
+	}
+
+	// follow the latest nagios problem, so acknowledgements still match the open incident
+	openItem.NagiosProblemId = event.NagiosProblemId
+	_, err = wh.dbClient.UpdateEventItem(*openItem)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update event item: %s ID %d %s", incidentName, openItem.Id, err.Error()))
+		return false, err
+	}
+
+	if openItem.NagiosProblemContent != event.NagiosProblemContent {
+		wh.commentPluginOutputUpdate(incidentName, *openItem, event.NagiosProblemContent)
+	}
+
+	wh.recordDecision(event, openItem.BetterStackIncidentId, DECISION_HELD, "object is flapping, problem attached to open incident")
+	fmt.Println("INFO Attached flapping problem to open incident: \"" + incidentName + "\"")
+	return true, nil
+}
+
+// Handle a RECOVERY notification for a flapping host/service, returns false if it should be handled as usual.
+// Caller must hold the database lock.
+func (wh *webHandler) handleFlappingRecovery(incidentName string, event models.EventItem) (bool, error) {
+	if wh.NagiosFlappingPolicy != FLAPPING_POLICY_INCIDENT && wh.NagiosFlappingPolicy != FLAPPING_POLICY_HOLD {
+		return false, nil
+	}
+
+	flapping, err := wh.getFlappingItem(event)
+	if err != nil || flapping == nil {
+		return false, err
+	}
+
+	wh.recordDecision(event, "", DECISION_HELD, "object is flapping, resolution held until flapping stops")
+	fmt.Println("INFO Holding resolution of flapping incident: \"" + incidentName + "\"")
+	return true, nil
+}
+
+func (wh *webHandler) handleFlappingStop(incidentName string, event models.EventItem) error {
+	flapping, err := wh.getFlappingItem(event)
+	if err != nil {
+		return err
+	}
+
+	if flapping == nil {
+		wh.recordDecision(event, "", DECISION_IGNORED, "flapping stopped, flapping was not tracked")
+		return nil
+	}
+
+	_, err = wh.dbClient.DeleteFlappingItem(flapping.Id)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to delete flapping item: %s ID %d %s", incidentName, flapping.Id, err.Error()))
+		return err
+	}
+	fmt.Println("INFO Flapping stopped: " + incidentName)
+
+	policyId := flapping.BetterStackPolicyId
+	if policyId == "" {
+		policyId = event.BetterStackPolicyId
+	}
+
+	return wh.reconcileWithNagiosState(incidentName, event, policyId, "flapping stopped")
+}
@@ -0,0 +1,237 @@
+package web
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+func isGroupMember(member models.GroupMemberItem, event models.EventItem) bool {
+	return member.NagiosSiteName == event.NagiosSiteName &&
+		member.NagiosProblemType == event.NagiosProblemType &&
+		member.NagiosProblemHostname == event.NagiosProblemHostname &&
+		member.NagiosProblemServiceName == event.NagiosProblemServiceName
+}
+
+// Add a PROBLEM to the incident of its group, when its routing rule groups problems and the group was started
+// within the window. Returns whether the problem was added, and the group key to start a group with otherwise.
+// Caller must hold the database lock.
+func (wh *webHandler) handleGroupedProblem(incidentName string, event models.EventItem) (bool, string, error) {
+	rule, _ := wh.Router.Route(event)
+	if rule == nil {
+		return false, "", nil
+	}
+
+	groupKey := rule.GroupKey(event)
+	if groupKey == "" {
+		return false, "", nil
+	}
+
+	members, err := wh.dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all group member items: " + err.Error())
+		return false, "", err
+	}
+
+	// start of every group with the key, by the event item holding its incident
+	groupStarts := map[int64]int64{}
+	for _, member := range members {
+		if member.GroupKey != groupKey {
+			continue
+		}
+		if isGroupMember(member, event) {
+			fmt.Println("INFO Ignoring superfluous nagios notification for grouped problem: \"" + incidentName + "\"")
+			return true, "", nil
+		}
+		if start, ok := groupStarts[member.EventItemId]; !ok || member.CreatedAt < start {
+			groupStarts[member.EventItemId] = member.CreatedAt
+		}
+	}
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return false, "", err
+	}
+
+	windowStart := time.Now().Add(-time.Duration(rule.GroupWindowSeconds) * time.Second).Unix()
+	for _, item := range items {
+		start, ok := groupStarts[item.Id]
+		if !ok || start < windowStart {
+			continue
+		}
+
+		_, err = wh.dbClient.CreateGroupMemberItem(models.GroupMemberItem{
+			EventItemId:              item.Id,
+			GroupKey:                 groupKey,
+			NagiosSiteName:           event.NagiosSiteName,
+			NagiosProblemId:          event.NagiosProblemId,
+			NagiosProblemType:        event.NagiosProblemType,
+			NagiosProblemHostname:    event.NagiosProblemHostname,
+			NagiosProblemServiceName: event.NagiosProblemServiceName,
+			CreatedAt:                time.Now().Unix(),
+		})
+		if err != nil {
+			fmt.Println("ERROR Failed to create group member item: " + incidentName + " " + err.Error())
+			return false, "", err
+		}
+
+		err = wh.commentIncidents(incidentName, item, "Also affected: "+incidentName+": "+event.NagiosProblemContent)
+		if err != nil {
+			fmt.Println("WARN Failed to comment on incident for grouped problem: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+		}
+
+		wh.recordDecision(event, item.BetterStackIncidentId, DECISION_GROUPED, "grouped by rule \""+rule.Name+"\" into incident of "+item.NagiosProblemHostname)
+		fmt.Println("INFO Added problem to group " + groupKey + ": " + incidentName)
+		return true, "", nil
+	}
+
+	return false, groupKey, nil
+}
+
+// Start a group with the incident just opened for the event.
+// Caller must hold the database lock.
+func (wh *webHandler) startGroup(incidentName, groupKey, incidentId string, event models.EventItem) error {
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return err
+	}
+
+	var eventItemId int64
+	for _, item := range items {
+		if item.BetterStackIncidentId == incidentId &&
+			item.NagiosSiteName == event.NagiosSiteName &&
+			item.NagiosProblemHostname == event.NagiosProblemHostname &&
+			item.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			eventItemId = item.Id
+		}
+	}
+	if eventItemId == 0 {
+		return fmt.Errorf("no event item for incident %s", incidentId)
+	}
+
+	_, err = wh.dbClient.CreateGroupMemberItem(models.GroupMemberItem{
+		EventItemId:              eventItemId,
+		GroupKey:                 groupKey,
+		NagiosSiteName:           event.NagiosSiteName,
+		NagiosProblemId:          event.NagiosProblemId,
+		NagiosProblemType:        event.NagiosProblemType,
+		NagiosProblemHostname:    event.NagiosProblemHostname,
+		NagiosProblemServiceName: event.NagiosProblemServiceName,
+		CreatedAt:                time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Println("ERROR Failed to create group member item: " + incidentName + " " + err.Error())
+		return err
+	}
+
+	fmt.Println("INFO Started group " + groupKey + ": " + incidentName)
+	return nil
+}
+
+// Members of the group whose incident the event item holds, other than the problem of the event item itself.
+// Caller must hold the database lock.
+func (wh *webHandler) getGroupMembers(item models.EventItem) ([]models.GroupMemberItem, error) {
+	members, err := wh.dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all group member items: " + err.Error())
+		return nil, err
+	}
+
+	itemMembers := []models.GroupMemberItem{}
+	for _, member := range members {
+		if member.EventItemId == item.Id && !isGroupMember(member, item) {
+			itemMembers = append(itemMembers, member)
+		}
+	}
+
+	return itemMembers, nil
+}
+
+// After the incident of their group was resolved while the problem holding it persists, e.g. to recreate it for a state change,
+// give the other members that persist an incident again. The group member items are deleted along with the event item.
+// Caller must hold the database lock.
+func (wh *webHandler) releaseGroupMembers(groupName string, item models.EventItem, members []models.GroupMemberItem) {
+	for _, member := range members {
+		memberEvent := models.EventItem{
+			NagiosSiteName:                member.NagiosSiteName,
+			NagiosProblemId:               member.NagiosProblemId,
+			NagiosProblemHostname:         member.NagiosProblemHostname,
+			NagiosProblemServiceName:      member.NagiosProblemServiceName,
+			NagiosProblemNotificationType: "PROBLEM",
+			BetterStackPolicyId:           item.BetterStackPolicyId,
+		}
+		memberName := identifyEvent(&memberEvent)
+
+		err := wh.reconcileWithNagiosState(memberName, memberEvent, item.BetterStackPolicyId, "incident of group "+groupName+" was resolved")
+		if err != nil {
+			fmt.Println("ERROR Failed to reconcile grouped problem: " + memberName + " " + err.Error())
+		}
+	}
+}
+
+// Handle the RECOVERY of a grouped problem, the incident of the group is only resolved when all members recovered.
+// Returns whether the problem was a member of a group.
+// Caller must hold the database lock.
+func (wh *webHandler) handleGroupedRecovery(incidentName string, event models.EventItem) (bool, error) {
+	members, err := wh.dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all group member items: " + err.Error())
+		return false, err
+	}
+
+	var recovered *models.GroupMemberItem
+	for _, member := range members {
+		if isGroupMember(member, event) {
+			recovered = &member
+			break
+		}
+	}
+	if recovered == nil {
+		return false, nil
+	}
+
+	_, err = wh.dbClient.DeleteGroupMemberItem(recovered.Id)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to delete group member item: %s ID %d %s", incidentName, recovered.Id, err.Error()))
+		return true, err
+	}
+
+	remaining := 0
+	for _, member := range members {
+		if member.EventItemId == recovered.EventItemId && member.Id != recovered.Id {
+			remaining++
+		}
+	}
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return true, err
+	}
+
+	for _, item := range items {
+		if item.Id != recovered.EventItemId {
+			continue
+		}
+
+		if remaining == 0 {
+			fmt.Println("INFO All grouped problems recovered, resolving incident: " + incidentName)
+			// failing to resolve is logged, like for a RECOVERY of a problem that isn't grouped
+			children, _ := wh.recoverEventItem(incidentName, item, event.InteractingUserEmail)
+			wh.releaseCorrelatedChildren(incidentName, children)
+			return true, nil
+		}
+
+		err = wh.commentIncidents(incidentName, item, fmt.Sprintf("Recovered: %s, %d affected object(s) remaining", incidentName, remaining))
+		if err != nil {
+			fmt.Println("WARN Failed to comment on incident for grouped recovery: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+		}
+		return true, nil
+	}
+
+	// the incident of the group is gone, handle the recovery as usual
+	return false, nil
+}
@@ -0,0 +1,172 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+)
+
+// Handle webhooks at the legacy /api/better-stack-event route, always for the first Better Stack destination,
+// whatever the default destinations are
+func (wh *webHandler) handleIncomingBetterStackWebhook(w http.ResponseWriter, r *http.Request) {
+	for _, destinationName := range wh.destinations.Names() {
+		destination, _ := wh.destinations.Get(destinationName)
+		if destination.Provider.Name() == INCIDENT_PROVIDER_BETTERSTACK {
+			r.SetPathValue("destination", destinationName)
+			wh.handleIncomingIncidentWebhook(w, r)
+			return
+		}
+	}
+
+	logRequest(r)
+	http.Error(w, "No Better Stack incident destination configured", http.StatusNotFound)
+}
+
+// Whether a webhook event is about the incident stored with an incident id
+func webhookMatches(event incidents.WebhookEvent, incidentId string) bool {
+	return incidentId == event.IncidentId || (event.LegacyIncidentId != "" && incidentId == event.LegacyIncidentId)
+}
+
+func (wh *webHandler) handleIncomingIncidentWebhook(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	destinationName := r.PathValue("destination")
+	if destinationName == "" {
+		if len(wh.destinations.Defaults()) == 0 {
+			http.Error(w, "No incident destinations configured", http.StatusBadRequest)
+			return
+		}
+		destinationName = wh.destinations.Defaults()[0]
+	}
+
+	destination, err := wh.destinations.Get(destinationName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	providerName := destination.Provider.Name()
+
+	event, err := destination.Provider.ParseWebhook(r)
+	if err != nil {
+		log.Println("ERROR Failed to parse " + destinationName + " webhook: " + err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// ack nagios services/host problems based off incident ID, only act on acknowledged and resolved events
+	if event.Status == incidents.STATUS_ACKNOWLEDGED || event.Status == incidents.STATUS_RESOLVED {
+		wh.dbClient.Lock()
+		defer wh.dbClient.Unlock()
+
+		var eventData models.EventItem
+
+		items, err := wh.dbClient.GetAllEventItems()
+		if err != nil {
+			log.Println("ERROR Failed to get all event items: " + err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		destinationItems, err := wh.dbClient.GetAllDestinationItems()
+		if err != nil {
+			log.Println("ERROR Failed to get all destination items: " + err.Error())
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// find the event item through the incident at this destination, falling back to
+		// the incident id of the event item for event items stored before destinations were tracked
+		eventItemId := int64(-1)
+		for _, destinationItem := range destinationItems {
+			if destinationItem.Destination == destinationName && webhookMatches(event, destinationItem.IncidentId) {
+				eventItemId = destinationItem.EventItemId
+				destinationItem.Status = event.Status
+				_, err = wh.dbClient.UpdateDestinationItem(destinationItem)
+				if err != nil {
+					log.Println(fmt.Sprintf("ERROR Failed to update destination item: ID %d %s", destinationItem.Id, err.Error()))
+				}
+			}
+		}
+
+		for _, item := range items {
+			if item.Id == eventItemId || (eventItemId == -1 && webhookMatches(event, item.BetterStackIncidentId)) {
+				eventData = item
+			}
+		}
+
+		if eventData.BetterStackIncidentId == "" {
+			log.Println("ERROR Could not find event for " + destinationName + " incident id: " + event.IncidentId)
+			http.Error(w, "Could not find event", http.StatusBadRequest)
+			return
+		} else {
+			nagiosClient, err := wh.nagiosSites.Get(eventData.NagiosSiteName)
+			if err != nil {
+				log.Println("ERROR Failed to get Nagios site for " + destinationName + " incident id " + event.IncidentId + ": " + err.Error())
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+
+			switch eventData.NagiosProblemType {
+			case "HOST":
+				// check if it is already acknowledged or recovered
+				hostState, err := nagiosClient.GetHostState(eventData.NagiosProblemHostname)
+				if errors.Is(err, nagios.ErrStateUnsupported) {
+					// nagios ignores acknowledgements of hosts without a problem
+					hostState, err = nagios.HostState{Acknowledged: 0, State: 1}, nil
+				}
+				if err != nil {
+					log.Println("ERROR Failed to get host ack state: " + eventData.NagiosProblemHostname)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				if hostState.Acknowledged == 0 && hostState.State != 0 {
+					err = nagiosClient.AckHost(eventData.NagiosProblemHostname, "Acknowledged by "+providerName)
+					if err != nil {
+						log.Println("ERROR Failed to acknowledge host: " + eventData.NagiosProblemHostname)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else {
+						log.Println("INFO Acknowledged host: " + eventData.NagiosProblemHostname)
+					}
+				} else {
+					log.Println("INFO Host already acknowledged, or recovered: " + eventData.NagiosProblemHostname)
+				}
+
+			case "SERVICE":
+				// check if it is already acknowledged or recovered
+				serviceState, err := nagiosClient.GetServiceState(eventData.NagiosProblemHostname, eventData.NagiosProblemServiceName)
+				if errors.Is(err, nagios.ErrStateUnsupported) {
+					// nagios ignores acknowledgements of services without a problem
+					serviceState, err = nagios.ServiceState{Acknowledged: 0, State: 2}, nil
+				}
+				if err != nil {
+					log.Println("ERROR Failed to get service ack state: " + eventData.NagiosProblemHostname + " " + eventData.NagiosProblemServiceName)
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+
+				if serviceState.Acknowledged == 0 && serviceState.State != 0 {
+					err = nagiosClient.AckService(eventData.NagiosProblemHostname, eventData.NagiosProblemServiceName, "Acknowledged by "+providerName)
+					if err != nil {
+						log.Println("ERROR Failed to acknowledge service: " + eventData.NagiosProblemHostname + " " + eventData.NagiosProblemServiceName)
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					} else {
+						log.Println("INFO Acknowledged service: " + eventData.NagiosProblemHostname + " " + eventData.NagiosProblemServiceName)
+					}
+				} else {
+					log.Println("INFO Service already acknowledged, or recovered: " + eventData.NagiosProblemHostname + " " + eventData.NagiosProblemServiceName)
+				}
+			}
+		}
+	}
+
+	// return success
+	w.WriteHeader(http.StatusOK)
+}
@@ -2,7 +2,11 @@ package web
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func (wh *webHandler) handleGetEventItems(w http.ResponseWriter, r *http.Request) {
@@ -19,3 +23,237 @@ func (wh *webHandler) handleGetEventItems(w http.ResponseWriter, r *http.Request
 	json.NewEncoder(w).Encode(events)
 
 }
+
+// Get the incidents opened for a stored event item at its destinations
+func (wh *webHandler) handleGetEventItemDestinations(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid event item id", http.StatusBadRequest)
+		return
+	}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, item := range items {
+		if item.Id != id {
+			continue
+		}
+
+		destinationItems, err := wh.getDestinationItems(item)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(destinationItems)
+		return
+	}
+
+	http.Error(w, "Event item not found", http.StatusNotFound)
+}
+
+type scheduleDowntimeRequest struct {
+	DurationMinutes int    `json:"durationMinutes"`
+	Comment         string `json:"comment"`
+}
+
+// Schedule Nagios downtime for the host/service of a stored event item
+func (wh *webHandler) handleScheduleEventItemDowntime(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid event item id", http.StatusBadRequest)
+		return
+	}
+
+	var downtimeRequest scheduleDowntimeRequest
+	err = json.NewDecoder(r.Body).Decode(&downtimeRequest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if downtimeRequest.DurationMinutes <= 0 {
+		http.Error(w, "Missing required field \"durationMinutes\"", http.StatusBadRequest)
+		return
+	}
+
+	if downtimeRequest.Comment == "" {
+		downtimeRequest.Comment = "Downtime scheduled from incident"
+	}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, item := range items {
+		if item.Id != id {
+			continue
+		}
+
+		nagiosClient, err := wh.nagiosSites.Get(item.NagiosSiteName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		start := time.Now()
+		end := start.Add(time.Minute * time.Duration(downtimeRequest.DurationMinutes))
+
+		switch item.NagiosProblemType {
+		case "HOST":
+			err = nagiosClient.ScheduleHostDowntime(item.NagiosProblemHostname, start, end, downtimeRequest.Comment)
+		case "SERVICE":
+			err = nagiosClient.ScheduleServiceDowntime(item.NagiosProblemHostname, item.NagiosProblemServiceName, start, end, downtimeRequest.Comment)
+		default:
+			err = fmt.Errorf("unknown problem type %q", item.NagiosProblemType)
+		}
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to schedule downtime for event item ID %d: %s", item.Id, err.Error()))
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+
+		fmt.Println(fmt.Sprintf("INFO Scheduled %d minute(s) of downtime for event item ID %d", downtimeRequest.DurationMinutes, item.Id))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Error(w, "Could not find event item", http.StatusNotFound)
+}
+
+func (wh *webHandler) handleGetDecisionItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	decisions, err := wh.dbClient.GetAllDecisionItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(decisions)
+}
+
+func (wh *webHandler) handleGetDowntimeItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	downtimes, err := wh.dbClient.GetAllDowntimeItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(downtimes)
+}
+
+func (wh *webHandler) handleGetFlappingItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	flapping, err := wh.dbClient.GetAllFlappingItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(flapping)
+}
+
+func (wh *webHandler) handleGetCorrelationItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	correlations, err := wh.dbClient.GetAllCorrelationItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(correlations)
+}
+
+func (wh *webHandler) handleGetGroupMemberItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	members, err := wh.dbClient.GetAllGroupMemberItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(members)
+}
+
+func (wh *webHandler) handleGetRateLimits(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wh.getRateLimitStatus())
+}
+
+// Prometheus text exposition of the rate limits and counters
+func (wh *webHandler) handleGetMetrics(w http.ResponseWriter, r *http.Request) {
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	status := wh.getRateLimitStatus()
+
+	var b strings.Builder
+	fmt.Fprintln(&b, "# HELP nbsc_incidents_created_total Incidents opened for Nagios problems since startup.")
+	fmt.Fprintln(&b, "# TYPE nbsc_incidents_created_total counter")
+	fmt.Fprintf(&b, "nbsc_incidents_created_total %d\n", status.IncidentsCreated)
+	fmt.Fprintln(&b, "# HELP nbsc_notifications_rate_limited_total Problems held back by rate limits since startup.")
+	fmt.Fprintln(&b, "# TYPE nbsc_notifications_rate_limited_total counter")
+	fmt.Fprintf(&b, "nbsc_notifications_rate_limited_total %d\n", status.NotificationsLimited)
+	fmt.Fprintln(&b, "# HELP nbsc_rate_limit_window_seconds Window incidents are counted in.")
+	fmt.Fprintln(&b, "# TYPE nbsc_rate_limit_window_seconds gauge")
+	fmt.Fprintf(&b, "nbsc_rate_limit_window_seconds %d\n", status.WindowSeconds)
+	fmt.Fprintln(&b, "# HELP nbsc_rate_limit_incidents Maximum incidents within the window.")
+	fmt.Fprintln(&b, "# TYPE nbsc_rate_limit_incidents gauge")
+	for _, scope := range status.Scopes {
+		fmt.Fprintf(&b, "nbsc_rate_limit_incidents{scope=%q} %d\n", scope.Scope, scope.Limit)
+	}
+	fmt.Fprintln(&b, "# HELP nbsc_rate_limit_window_incidents Incidents to be opened within the current window.")
+	fmt.Fprintln(&b, "# TYPE nbsc_rate_limit_window_incidents gauge")
+	for _, scope := range status.Scopes {
+		fmt.Fprintf(&b, "nbsc_rate_limit_window_incidents{scope=%q} %d\n", scope.Scope, scope.Incidents)
+	}
+	fmt.Fprintln(&b, "# HELP nbsc_notification_storm Whether a notification storm is ongoing.")
+	fmt.Fprintln(&b, "# TYPE nbsc_notification_storm gauge")
+	for _, scope := range status.Scopes {
+		storm := 0
+		if scope.Storm {
+			storm = 1
+		}
+		fmt.Fprintf(&b, "nbsc_notification_storm{scope=%q} %d\n", scope.Scope, storm)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte(b.String()))
+}
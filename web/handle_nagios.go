@@ -3,16 +3,27 @@ package web
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
 	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
 )
 
 func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r *http.Request) {
 	logRequest(r)
+
+	if wh.NagiosEventToken != "" && !hasBearerToken(r, wh.NagiosEventToken) {
+		http.Error(w, "Invalid or missing notification token", http.StatusUnauthorized)
+		fmt.Println("WARN Rejecting notification with invalid token")
+		return
+	}
+
 	wh.dbClient.Lock()
 	defer wh.dbClient.Unlock()
 	var event models.EventItem
@@ -33,29 +44,38 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 
 	if event.NagiosSiteName == "" ||
 		event.NagiosProblemNotificationType == "" ||
-		event.NagiosProblemHostname == "" ||
-		event.BetterStackPolicyId == "" {
+		event.NagiosProblemHostname == "" {
 		http.Error(w, "Missing required fields", http.StatusBadRequest)
 		fmt.Println("INFO Missing required fields, ignoring: " + bodyString)
 		return
 	}
 
-	incidentName := "placeholder - incident name - something went wrong"
+	_, err = wh.nagiosSites.Get(event.NagiosSiteName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		fmt.Println("WARN Rejecting notification from unknown site: " + event.NagiosSiteName)
+		return
+	}
 
-	// identify event as either host or service problem
-	if event.NagiosProblemServiceName != "" {
+	err = wh.validateEventDestinations(event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		fmt.Println("WARN Rejecting notification with unknown incident destination: " + err.Error())
+		return
+	}
 
-		serviceName := event.NagiosProblemServiceName
+	incidentName := identifyEvent(&event)
 
-		if strings.TrimSpace(event.NagiosProblemServiceDisplayName) != "" {
-			serviceName = event.NagiosProblemServiceDisplayName
-		}
+	err = wh.routeEvent(&event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-		incidentName = fmt.Sprintf("[%s] - [%s]", event.NagiosProblemHostname, serviceName)
-		event.NagiosProblemType = "SERVICE"
-	} else {
-		incidentName = fmt.Sprintf("[%s]", event.NagiosProblemHostname)
-		event.NagiosProblemType = "HOST"
+	if event.BetterStackPolicyId == "" {
+		http.Error(w, "Missing \"betterStackPolicyId\", and no routing rule matched", http.StatusBadRequest)
+		fmt.Println("INFO No policy for notification, ignoring: " + bodyString)
+		return
 	}
 
 	fmt.Println("INFO Incoming notification: " + incidentName + " nagiosProblemId " + event.NagiosProblemId)
@@ -67,6 +87,17 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 			http.Error(w, "Missing required field \"nagiosProblemId\"", http.StatusBadRequest)
 			return
 		}
+		// repeat notifications of problems with open incidents are suppressed as well
+		suppressed, err := wh.suppressProblem(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if suppressed {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
 		// check if incident already exists
 		events, err := wh.dbClient.GetAllEventItems()
 		if err != nil {
@@ -79,30 +110,34 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 				item.NagiosProblemType == event.NagiosProblemType &&
 				item.NagiosSiteName == event.NagiosSiteName &&
 				item.BetterStackPolicyId == event.BetterStackPolicyId {
-				fmt.Println("INFO Ignoring superfluous nagios notification for incident: \"" + incidentName + "\"")
+				// events stored before states were sent have no state to compare
+				if item.NagiosProblemState != "" && event.NagiosProblemState != "" && item.NagiosProblemState != event.NagiosProblemState {
+					err := wh.handleStateChange(incidentName, item, event)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusInternalServerError)
+						return
+					}
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+
+				if item.NagiosProblemContent == event.NagiosProblemContent {
+					fmt.Println("INFO Ignoring superfluous nagios notification for incident: \"" + incidentName + "\"")
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+
+				wh.commentPluginOutputUpdate(incidentName, item, event.NagiosProblemContent)
 				w.WriteHeader(http.StatusOK)
 				return
 			}
 		}
 
-		fmt.Println("INFO Creating incident: " + incidentName)
-		betterStackIncidentId, err := wh.betterClient.CreateIncident(event.BetterStackPolicyId, wh.BetterStackDefaultContactEmail, incidentName, event.NagiosProblemContent)
+		_, err = wh.handleNewProblem(incidentName, event)
 		if err != nil {
-			fmt.Println("ERROR Failed to create incident: " + incidentName + " " + err.Error())
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-
-		event.BetterStackIncidentId = betterStackIncidentId
-
-		_, err = wh.dbClient.CreateEventItem(event)
-		if err != nil {
-			fmt.Println("ERROR Failed to create event item: " + incidentName + " " + err.Error())
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		fmt.Println("INFO Created incident: " + incidentName)
 	case "ACKNOWLEDGEMENT":
 		items, _ := wh.dbClient.GetAllEventItems()
 
@@ -113,15 +148,65 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 				item.NagiosProblemServiceName == event.NagiosProblemServiceName &&
 				item.NagiosProblemType == event.NagiosProblemType &&
 				item.BetterStackPolicyId == event.BetterStackPolicyId {
-				ackerr := wh.betterClient.AcknowledgeIncident(event.InteractingUserEmail, wh.BetterStackDefaultContactEmail, item.BetterStackIncidentId)
+				ackerr := wh.acknowledgeIncidents(incidentName, item, event.InteractingUserEmail)
 				if ackerr != nil {
-					fmt.Println("WARN Failed to acknowledge incident: " + incidentName + " BetterStack incident ID " + item.BetterStackIncidentId + " " + ackerr.Error())
+					fmt.Println("WARN Failed to acknowledge incident: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + ackerr.Error())
 				} else {
-					fmt.Println("INFO Acknowledged incident: " + incidentName + " BetterStack incident ID " + item.BetterStackIncidentId)
+					fmt.Println("INFO Acknowledged incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+				}
+
+				if strings.TrimSpace(event.NagiosProblemAckComment) != "" {
+					ackedBy := event.InteractingUserEmail
+					if ackedBy == "" {
+						ackedBy = "unknown user"
+					}
+					comment := fmt.Sprintf("Acknowledged in Nagios by %s: %s", ackedBy, event.NagiosProblemAckComment)
+					commenterr := wh.commentIncidents(incidentName, item, comment)
+					if commenterr != nil {
+						fmt.Println("WARN Failed to add ack comment to incident: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + commenterr.Error())
+					} else {
+						fmt.Println("INFO Added ack comment to incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+					}
 				}
 			}
 		}
 	case "RECOVERY":
+		cancelled, err := wh.handlePendingRecovery(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if cancelled {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		held, err := wh.handleFlappingRecovery(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if held {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		_, err = wh.handleCorrelatedRecovery(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		grouped, err := wh.handleGroupedRecovery(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if grouped {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
 		items, err := wh.dbClient.GetAllEventItems()
 		if err != nil {
 			fmt.Println("ERROR Failed to get all event items: " + err.Error())
@@ -135,20 +220,36 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 				item.NagiosProblemHostname == event.NagiosProblemHostname &&
 				item.NagiosProblemServiceName == event.NagiosProblemServiceName &&
 				item.BetterStackPolicyId == event.BetterStackPolicyId {
-				ackerr := wh.betterClient.ResolveIncident(event.InteractingUserEmail, wh.BetterStackDefaultContactEmail, item.BetterStackIncidentId)
-				if ackerr != nil {
-					fmt.Println("WARN Failed to resolve incident: " + incidentName + " BetterStack incident ID " + item.BetterStackIncidentId + " " + ackerr.Error())
-				} else {
-					fmt.Println("INFO Resolved incident: " + incidentName + " BetterStack incident ID " + item.BetterStackIncidentId)
-				}
-				_, delerr := wh.dbClient.DeleteEventItem(item.Id)
-				if delerr != nil {
-					fmt.Println(fmt.Sprintf("ERROR Failed to delete event item: %s ID %d %s", incidentName, item.Id, delerr.Error()))
-				} else {
-					fmt.Println(fmt.Sprintf("INFO Deleted event item: %s ID %d", incidentName, item.Id))
-				}
+				children, _ := wh.recoverEventItem(incidentName, item, event.InteractingUserEmail)
+
+				// problems attached to the host incident that persist get their own incident
+				wh.releaseCorrelatedChildren(incidentName, children)
 			}
 		}
+	case "DOWNTIMESTART":
+		err := wh.handleDowntimeStart(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "DOWNTIMEEND", "DOWNTIMECANCELLED":
+		err := wh.handleDowntimeEnd(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "FLAPPINGSTART":
+		err := wh.handleFlappingStart(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	case "FLAPPINGSTOP", "FLAPPINGDISABLED":
+		err := wh.handleFlappingStop(incidentName, event)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
 	default:
 		// ignore it
 		fmt.Println("INFO Ignoring incoming notification: " + incidentName + " STATUS " + event.NagiosProblemNotificationType)
@@ -157,3 +258,381 @@ func (wh *webHandler) handleIncomingNagiosNotification(w http.ResponseWriter, r
 	// return success
 	w.WriteHeader(http.StatusOK)
 }
+
+// Set the problem type of the event, and return the incident name for it
+func identifyEvent(event *models.EventItem) string {
+	// identify event as either host or service problem
+	if event.NagiosProblemServiceName != "" {
+
+		serviceName := event.NagiosProblemServiceName
+
+		if strings.TrimSpace(event.NagiosProblemServiceDisplayName) != "" {
+			serviceName = event.NagiosProblemServiceDisplayName
+		}
+
+		event.NagiosProblemType = "SERVICE"
+		return fmt.Sprintf("[%s] - [%s]", event.NagiosProblemHostname, serviceName)
+	}
+
+	event.NagiosProblemType = "HOST"
+	return fmt.Sprintf("[%s]", event.NagiosProblemHostname)
+}
+
+// Key identifying the nagios problem of the event, used by providers to deduplicate incidents
+func problemKey(event models.EventItem) string {
+	return fmt.Sprintf("nbsc:%s:%s:%s:%s", event.NagiosSiteName, event.NagiosProblemHostname, event.NagiosProblemServiceName, event.NagiosProblemId)
+}
+
+// Suppress a PROBLEM for a host/service in scheduled downtime, or matching an active suppression rule.
+// Returns whether the problem was suppressed.
+// Caller must hold the database lock.
+func (wh *webHandler) suppressProblem(incidentName string, event models.EventItem) (bool, error) {
+	inDowntime, err := wh.handleDowntimeProblem(incidentName, event)
+	if err != nil || inDowntime {
+		return inDowntime, err
+	}
+
+	suppression, err := wh.findSuppression(event)
+	if err != nil || suppression == nil {
+		return false, err
+	}
+
+	wh.attachToSuppression(incidentName, event, *suppression)
+	wh.recordSuppression(event, *suppression)
+	fmt.Println(fmt.Sprintf("INFO Suppressing notification by suppression rule ID %d: \"%s\"", suppression.Id, incidentName))
+	return true, nil
+}
+
+// Handle a PROBLEM without an open incident, that wasn't suppressed: ignore states that aren't paged,
+// hold flapping problems and problems with a grace period, and open an incident for the rest.
+// Returns the id of the incident opened, or empty when none was.
+// Caller must hold the database lock.
+func (wh *webHandler) handleNewProblem(incidentName string, event models.EventItem) (string, error) {
+	paged, reason := wh.isPagedState(event)
+	if !paged {
+		wh.recordDecision(event, "", DECISION_IGNORED, reason)
+		fmt.Println("INFO Not paging notification, " + reason + ": \"" + incidentName + "\"")
+		return "", nil
+	}
+
+	handled, err := wh.handleFlappingProblem(incidentName, event)
+	if err != nil || handled {
+		return "", err
+	}
+
+	pending, err := wh.handlePendingProblem(incidentName, event)
+	if err != nil || pending {
+		return "", err
+	}
+
+	return wh.openProblemIncident(incidentName, event)
+}
+
+// Open an incident for a new PROBLEM, unless it is attached to the incident of its host, grouped into
+// the incident of other problems, or held back by the rate limits.
+// Returns the id of the incident opened, or empty when none was.
+// Caller must hold the database lock.
+func (wh *webHandler) openProblemIncident(incidentName string, event models.EventItem) (string, error) {
+	correlated, err := wh.handleCorrelatedProblem(incidentName, event)
+	if err != nil || correlated {
+		return "", err
+	}
+
+	grouped, groupKey, err := wh.handleGroupedProblem(incidentName, event)
+	if err != nil || grouped {
+		return "", err
+	}
+
+	limited, err := wh.handleRateLimitedProblem(incidentName, event)
+	if err != nil || limited {
+		return "", err
+	}
+
+	incidentId, err := wh.createIncident(incidentName, event)
+	if err != nil {
+		return "", err
+	}
+
+	if groupKey != "" {
+		err = wh.startGroup(incidentName, groupKey, incidentId, event)
+		if err != nil {
+			fmt.Println("WARN Failed to start group for incident: " + incidentName + " " + err.Error())
+		}
+	}
+
+	return incidentId, nil
+}
+
+// Create incidents for the event at its destinations, and store the event item and its destination items.
+// Returns the id of the incident at the first destination.
+func (wh *webHandler) createIncident(incidentName string, event models.EventItem) (string, error) {
+	fmt.Println("INFO Creating incident: " + incidentName)
+	incident := incidents.Incident{
+		ProblemKey:  problemKey(event),
+		PolicyId:    event.BetterStackPolicyId,
+		Name:        incidentName,
+		Summary:     event.NagiosProblemContent,
+		Description: event.NagiosProblemContent,
+		Source:      event.NagiosProblemHostname,
+	}
+
+	rule, _ := wh.Router.Route(event)
+	if rule != nil {
+		rule.Apply(&incident)
+		fmt.Println("INFO Routing incident by rule \"" + rule.Name + "\": " + incidentName)
+	}
+
+	wh.applyIncidentChannels(incidentName, event, rule, &incident)
+	wh.applyIncidentTemplates(incidentName, event, rule, &incident)
+
+	now := time.Now()
+	recurrence, err := wh.findRecurrenceItem(event)
+	if err != nil {
+		return "", err
+	}
+	recurred := wh.isRecurrence(recurrence, now)
+	if recurred && wh.IncidentRecurrencePolicy == RECURRENCE_POLICY_REFERENCE {
+		referencePreviousIncident(&incident, *recurrence, now)
+	}
+
+	destinationItems, err := wh.openDestinationIncidents(incidentName, event, incident)
+	if err != nil {
+		fmt.Println("ERROR Failed to create incident: " + incidentName + " " + err.Error())
+		return "", err
+	}
+
+	incidentId, err := wh.storeEventItem(incidentName, event, destinationItems)
+	if err != nil {
+		return "", err
+	}
+
+	// the incidents are open, failing to track their history shouldn't fail the notification
+	wh.recordIncidentOpened(incidentName, event, recurrence, recurred, destinationItems)
+
+	wh.RateLimiter.IncidentsCreated++
+	fmt.Println("INFO Created incident: " + incidentName)
+	return incidentId, nil
+}
+
+// Store the event item for incidents just opened, along with their destination items.
+// Returns the id of the incident at the first destination.
+// Caller must hold the database lock.
+func (wh *webHandler) storeEventItem(incidentName string, event models.EventItem, destinationItems []models.DestinationItem) (string, error) {
+	incidentId := destinationItems[0].IncidentId
+	event.BetterStackIncidentId = incidentId
+
+	eventItemId, err := wh.dbClient.CreateEventItem(event)
+	if err != nil {
+		fmt.Println("ERROR Failed to create event item: " + incidentName + " " + err.Error())
+		return "", err
+	}
+
+	for _, destinationItem := range destinationItems {
+		destinationItem.EventItemId = eventItemId
+		_, err = wh.dbClient.CreateDestinationItem(destinationItem)
+		if err != nil {
+			fmt.Println("ERROR Failed to create destination item: " + incidentName + " " + err.Error())
+			return "", err
+		}
+	}
+
+	return incidentId, nil
+}
+
+// Find the stored event item with an open incident for the same host/service as the event, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findOpenEventItem(event models.EventItem) (*models.EventItem, error) {
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.NagiosSiteName == event.NagiosSiteName &&
+			item.NagiosProblemType == event.NagiosProblemType &&
+			item.NagiosProblemHostname == event.NagiosProblemHostname &&
+			item.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			return &item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Get the current Nagios state and plugin output of the host/service of the event
+func (wh *webHandler) getNagiosProblemState(event models.EventItem) (int, string, error) {
+	nagiosClient, err := wh.nagiosSites.Get(event.NagiosSiteName)
+	if err != nil {
+		return 0, "", err
+	}
+
+	switch event.NagiosProblemType {
+	case "HOST":
+		hostState, err := nagiosClient.GetHostState(event.NagiosProblemHostname)
+		if err != nil {
+			fmt.Println("ERROR Failed to get host state: " + event.NagiosProblemHostname + " " + err.Error())
+			return 0, "", err
+		}
+		if hostState.PluginOutput == "" {
+			return hostState.State, event.NagiosProblemContent, nil
+		}
+		return hostState.State, hostState.PluginOutput, nil
+	case "SERVICE":
+		serviceState, err := nagiosClient.GetServiceState(event.NagiosProblemHostname, event.NagiosProblemServiceName)
+		if err != nil {
+			fmt.Println("ERROR Failed to get service state: " + event.NagiosProblemHostname + " " + event.NagiosProblemServiceName + " " + err.Error())
+			return 0, "", err
+		}
+		if serviceState.CheckOutput == "" {
+			return serviceState.State, event.NagiosProblemContent, nil
+		}
+		return serviceState.State, serviceState.CheckOutput, nil
+	}
+
+	return 0, "", fmt.Errorf("unknown problem type %q", event.NagiosProblemType)
+}
+
+// After a period in which notifications were suppressed or held (downtime, flapping), bring the incident in line with the
+// current Nagios state: comment on or recreate the incident of a persisting problem, or resolve the incident of a recovered one.
+// Caller must hold the database lock.
+func (wh *webHandler) reconcileWithNagiosState(incidentName string, event models.EventItem, policyId, cause string) error {
+	problemState, problemOutput, err := wh.getNagiosProblemState(event)
+	if errors.Is(err, nagios.ErrStateUnsupported) {
+		wh.recordDecision(event, "", DECISION_IGNORED, cause+", state of the site can't be looked up")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	openItem, err := wh.findOpenEventItem(event)
+	if err != nil {
+		return err
+	}
+
+	// incidents resolved at their providers in the meantime are no longer tracked, a persisting problem gets a new one
+	var children []models.CorrelationItem
+	if openItem != nil && wh.incidentsResolved(incidentName, *openItem) {
+		fmt.Println("INFO Incident was resolved at its destinations: " + incidentName + " incident ID " + openItem.BetterStackIncidentId)
+		children, _ = wh.getCorrelatedChildren(*openItem)
+		err = wh.deleteEventItem(incidentName, *openItem)
+		if err != nil {
+			return err
+		}
+		wh.recordRecovery(incidentName, *openItem)
+		openItem = nil
+	}
+
+	switch {
+	case problemState != 0 && openItem != nil:
+		err := wh.commentIncidents(incidentName, *openItem, "Nagios "+cause+", the problem persists: "+problemOutput)
+		if err != nil {
+			fmt.Println("WARN Failed to comment on incident: " + incidentName + " incident ID " + openItem.BetterStackIncidentId + " " + err.Error())
+		}
+		wh.recordDecision(event, openItem.BetterStackIncidentId, DECISION_COMMENTED, cause+", problem persists on open incident")
+	case problemState != 0:
+		// the persisting problem is handled like a new PROBLEM notification in its current state
+		recreated := event
+		recreated.NagiosProblemNotificationType = "PROBLEM"
+		recreated.NagiosProblemState = nagios.StateName(event.NagiosProblemType, problemState)
+		recreated.NagiosProblemStateType = ""
+		recreated.NagiosProblemContent = problemOutput
+		recreated.BetterStackPolicyId = policyId
+
+		suppressed, err := wh.suppressProblem(incidentName, recreated)
+		if err != nil {
+			return err
+		}
+		if suppressed {
+			break
+		}
+
+		incidentId, err := wh.handleNewProblem(incidentName, recreated)
+		if err != nil {
+			return err
+		}
+		if incidentId != "" {
+			wh.recordDecision(event, incidentId, DECISION_RECREATED, cause+", problem persists")
+		}
+	case openItem != nil:
+		children, err = wh.recoverEventItem(incidentName, *openItem, event.InteractingUserEmail)
+		if err != nil {
+			wh.releaseCorrelatedChildren(incidentName, children)
+			return err
+		}
+		wh.recordDecision(event, openItem.BetterStackIncidentId, DECISION_RESOLVED, cause+", problem recovered in the meantime")
+	default:
+		wh.recordDecision(event, "", DECISION_IGNORED, cause+", no problem")
+	}
+
+	// problems attached to the incident that is gone get their own incident, if they persist
+	wh.releaseCorrelatedChildren(incidentName, children)
+
+	return nil
+}
+
+// Resolve the incident of the event item, and delete the event item.
+// Caller must hold the database lock.
+func (wh *webHandler) resolveEventItem(incidentName string, item models.EventItem, interactingUserEmail string) error {
+	err := wh.resolveIncidents(incidentName, item, interactingUserEmail)
+	if err != nil {
+		fmt.Println("WARN Failed to resolve incident: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+		return err
+	}
+	fmt.Println("INFO Resolved incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+
+	return wh.deleteEventItem(incidentName, item)
+}
+
+// Resolve the incidents of the event item for a recovered problem, delete the event item and note the recovery.
+// The event item is deleted even when resolving fails at a destination, as Nagios doesn't repeat a RECOVERY.
+// Returns the problems that were attached to the incident, for the caller to release with releaseCorrelatedChildren
+// after opening any new incident, and the error resolving the incidents.
+// Caller must hold the database lock.
+func (wh *webHandler) recoverEventItem(incidentName string, item models.EventItem, interactingUserEmail string) ([]models.CorrelationItem, error) {
+	children, _ := wh.getCorrelatedChildren(item)
+
+	resolveErr := wh.resolveIncidents(incidentName, item, interactingUserEmail)
+	if resolveErr != nil {
+		fmt.Println("WARN Failed to resolve incident: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + resolveErr.Error())
+	} else {
+		fmt.Println("INFO Resolved incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+	}
+
+	err := wh.deleteEventItem(incidentName, item)
+	wh.recordRecovery(incidentName, item)
+	if err != nil {
+		return children, err
+	}
+
+	return children, resolveErr
+}
+
+// Append changed plugin output of a repeat PROBLEM notification to the incident timeline,
+// at most once per IncidentCommentInterval so noisy checks can't flood the incident.
+// Throttled updates are dropped without touching the stored output, so the next
+// notification after the interval still sees the change.
+func (wh *webHandler) commentPluginOutputUpdate(incidentName string, item models.EventItem, content string) {
+	now := time.Now()
+	if now.Sub(time.Unix(item.LastCommentedAt, 0)) < wh.IncidentCommentInterval {
+		fmt.Println("INFO Throttling plugin output update for incident: \"" + incidentName + "\"")
+		return
+	}
+
+	err := wh.commentIncidents(incidentName, item, "Nagios plugin output changed: "+content)
+	if err != nil {
+		fmt.Println("WARN Failed to add plugin output comment to incident: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+		return
+	}
+
+	item.NagiosProblemContent = content
+	item.LastCommentedAt = now.Unix()
+	_, err = wh.dbClient.UpdateEventItem(item)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update event item: %s ID %d %s", incidentName, item.Id, err.Error()))
+		return
+	}
+
+	fmt.Println("INFO Added plugin output comment to incident: " + incidentName + " incident ID " + item.BetterStackIncidentId)
+}
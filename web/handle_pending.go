@@ -0,0 +1,196 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	// back-off for retrying to open the incident of a pending problem
+	pendingRetryDelay    = 15 * time.Second
+	pendingRetryMaxDelay = 5 * time.Minute
+)
+
+// Find the pending PROBLEM for the same host/service as the event, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findPendingItem(event models.EventItem) (*models.PendingItem, error) {
+	pendingItems, err := wh.dbClient.GetAllPendingItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all pending items: " + err.Error())
+		return nil, err
+	}
+
+	for _, pendingItem := range pendingItems {
+		if pendingItem.NagiosSiteName == event.NagiosSiteName &&
+			pendingItem.NagiosProblemType == event.NagiosProblemType &&
+			pendingItem.NagiosProblemHostname == event.NagiosProblemHostname &&
+			pendingItem.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			return &pendingItem, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Hold a new PROBLEM for the grace period of its routing rule, a repeat PROBLEM updates the held one.
+// Returns whether the problem is pending.
+// Caller must hold the database lock.
+func (wh *webHandler) handlePendingProblem(incidentName string, event models.EventItem) (bool, error) {
+	pendingItem, err := wh.findPendingItem(event)
+	if err != nil {
+		return false, err
+	}
+
+	if pendingItem != nil {
+		// the incident is opened with the latest output and state, when the grace period is over
+		pendingItem.Event = event
+		_, err = wh.dbClient.UpdatePendingItem(*pendingItem)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to update pending item: %s ID %d %s", incidentName, pendingItem.Id, err.Error()))
+			return false, err
+		}
+		fmt.Println("INFO Updated pending problem: " + incidentName)
+		return true, nil
+	}
+
+	rule, _ := wh.Router.Route(event)
+	if rule == nil || rule.GraceSeconds <= 0 {
+		return false, nil
+	}
+
+	now := time.Now()
+	_, err = wh.dbClient.CreatePendingItem(models.PendingItem{
+		NagiosSiteName:           event.NagiosSiteName,
+		NagiosProblemType:        event.NagiosProblemType,
+		NagiosProblemHostname:    event.NagiosProblemHostname,
+		NagiosProblemServiceName: event.NagiosProblemServiceName,
+		Event:                    event,
+		CreatedAt:                now.Unix(),
+		DueAt:                    now.Add(time.Duration(rule.GraceSeconds) * time.Second).Unix(),
+	})
+	if err != nil {
+		fmt.Println("ERROR Failed to create pending item: " + incidentName + " " + err.Error())
+		return false, err
+	}
+
+	reason := fmt.Sprintf("grace period of %d second(s) of rule \"%s\"", rule.GraceSeconds, rule.Name)
+	wh.recordDecision(event, "", DECISION_PENDING, reason)
+	fmt.Println("INFO Holding problem for " + reason + ": " + incidentName)
+	return true, nil
+}
+
+// Cancel the pending PROBLEM of a RECOVERY arriving within the grace period, without opening an incident.
+// Returns whether a pending problem was cancelled.
+// Caller must hold the database lock.
+func (wh *webHandler) handlePendingRecovery(incidentName string, event models.EventItem) (bool, error) {
+	pendingItem, err := wh.findPendingItem(event)
+	if err != nil || pendingItem == nil {
+		return false, err
+	}
+
+	_, err = wh.dbClient.DeletePendingItem(pendingItem.Id)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to delete pending item: %s ID %d %s", incidentName, pendingItem.Id, err.Error()))
+		return false, err
+	}
+
+	wh.recordDecision(event, "", DECISION_IGNORED, "recovered within the grace period")
+	fmt.Println("INFO Cancelled pending problem, recovered within the grace period: " + incidentName)
+	return true, nil
+}
+
+// Open incidents for pending problems whose grace period is over.
+// Caller must hold the database lock.
+func (wh *webHandler) releasePendingProblems() {
+	pendingItems, err := wh.dbClient.GetAllPendingItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all pending items: " + err.Error())
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, pendingItem := range pendingItems {
+		if pendingItem.DueAt > now {
+			continue
+		}
+
+		event := pendingItem.Event
+		incidentName := identifyEvent(&event)
+
+		// the host/service may have entered downtime or a suppression window during the grace period
+		suppressed, err := wh.suppressProblem(incidentName, event)
+		if err != nil {
+			wh.retryPendingItem(incidentName, pendingItem, err)
+			continue
+		}
+
+		if !suppressed {
+			fmt.Println("INFO Grace period over, opening incident: " + incidentName)
+			_, err = wh.openProblemIncident(incidentName, event)
+			if err != nil {
+				wh.retryPendingItem(incidentName, pendingItem, err)
+				continue
+			}
+		}
+
+		_, err = wh.dbClient.DeletePendingItem(pendingItem.Id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete pending item: %s ID %d %s", incidentName, pendingItem.Id, err.Error()))
+		}
+	}
+}
+
+// Record a failed attempt to open the incident of a pending problem, and retry it after a back-off
+// doubling with every attempt, up to pendingRetryMaxDelay.
+// Caller must hold the database lock.
+func (wh *webHandler) retryPendingItem(incidentName string, pendingItem models.PendingItem, err error) {
+	delay := pendingRetryMaxDelay
+	if pendingItem.Attempts < 8 {
+		delay = min(pendingRetryDelay<<pendingItem.Attempts, pendingRetryMaxDelay)
+	}
+
+	pendingItem.Attempts++
+	pendingItem.LastError = err.Error()
+	pendingItem.DueAt = time.Now().Add(delay).Unix()
+
+	reason := fmt.Sprintf("opening incident failed on attempt %d, retrying in %s: %s", pendingItem.Attempts, delay, err.Error())
+	wh.recordDecision(pendingItem.Event, "", DECISION_FAILED, reason)
+	fmt.Println("ERROR Failed to open incident for pending problem, " + reason + ": " + incidentName)
+
+	_, err = wh.dbClient.UpdatePendingItem(pendingItem)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update pending item: %s ID %d %s", incidentName, pendingItem.Id, err.Error()))
+	}
+}
+
+// Pending problems are stored, so those held before a restart are released after it
+func (wh *webHandler) startPendingRoutine() {
+	go func() {
+		for {
+			time.Sleep(time.Second * 5)
+			func() {
+				wh.dbClient.Lock()
+				defer wh.dbClient.Unlock()
+				wh.releasePendingProblems()
+			}()
+		}
+	}()
+}
+
+func (wh *webHandler) handleGetPendingItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	pendingItems, err := wh.dbClient.GetAllPendingItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(pendingItems)
+}
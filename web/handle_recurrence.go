@@ -0,0 +1,152 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// There is no policy reopening the previous incident: Better Stack, PagerDuty and Opsgenie can't reopen resolved incidents,
+// and a PagerDuty or Opsgenie incident triggered again with the same key is a new incident anyway.
+const (
+	// only count recurrences
+	RECURRENCE_POLICY_NONE = "none"
+	// reference the previous incident in the new one, and comment the new incident on the previous one
+	RECURRENCE_POLICY_REFERENCE = "reference"
+)
+
+// Find the incident history of the host/service of the event, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findRecurrenceItem(event models.EventItem) (*models.RecurrenceItem, error) {
+	recurrences, err := wh.dbClient.GetAllRecurrenceItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all recurrence items: " + err.Error())
+		return nil, err
+	}
+
+	for _, recurrence := range recurrences {
+		if recurrence.NagiosSiteName == event.NagiosSiteName &&
+			recurrence.NagiosProblemType == event.NagiosProblemType &&
+			recurrence.NagiosProblemHostname == event.NagiosProblemHostname &&
+			recurrence.NagiosProblemServiceName == event.NagiosProblemServiceName {
+			return &recurrence, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Whether a new incident is a recurrence, the last incident of the host/service recovered within the window
+func (wh *webHandler) isRecurrence(recurrence *models.RecurrenceItem, now time.Time) bool {
+	return recurrence != nil &&
+		recurrence.LastRecoveredAt != 0 &&
+		now.Sub(time.Unix(recurrence.LastRecoveredAt, 0)) <= wh.IncidentRecurrenceWindow
+}
+
+func lastIncidentIds(recurrence models.RecurrenceItem) string {
+	ids := []string{}
+	for _, destinationItem := range recurrence.LastIncidents {
+		ids = append(ids, destinationItem.Destination+" "+destinationItem.IncidentId)
+	}
+	return strings.Join(ids, ", ")
+}
+
+// Mention the previous incident of a recurring problem in the description of the new incident
+func referencePreviousIncident(incident *incidents.Incident, recurrence models.RecurrenceItem, now time.Time) {
+	since := now.Sub(time.Unix(recurrence.LastRecoveredAt, 0)).Round(time.Second)
+	incident.Description += fmt.Sprintf("\n\nThe problem returned %s after its previous incident (%s) recovered, %d recurrence(s) so far.",
+		since, lastIncidentIds(recurrence), recurrence.Recurrences+1)
+}
+
+// Update the incident history of the host/service after opening incidents for the event,
+// and comment the new incident on the previous one of a recurring problem.
+// Caller must hold the database lock.
+func (wh *webHandler) recordIncidentOpened(incidentName string, event models.EventItem, recurrence *models.RecurrenceItem, recurred bool, destinationItems []models.DestinationItem) error {
+	now := time.Now()
+
+	if recurrence == nil {
+		_, err := wh.dbClient.CreateRecurrenceItem(models.RecurrenceItem{
+			NagiosSiteName:           event.NagiosSiteName,
+			NagiosProblemType:        event.NagiosProblemType,
+			NagiosProblemHostname:    event.NagiosProblemHostname,
+			NagiosProblemServiceName: event.NagiosProblemServiceName,
+			Incidents:                1,
+			LastIncidents:            destinationItems,
+			LastOpenedAt:             now.Unix(),
+		})
+		if err != nil {
+			fmt.Println("ERROR Failed to create recurrence item: " + incidentName + " " + err.Error())
+		}
+		return err
+	}
+
+	if recurred {
+		recurrence.Recurrences++
+		fmt.Println(fmt.Sprintf("INFO Problem returned within the recurrence window, %d recurrence(s): %s", recurrence.Recurrences, incidentName))
+
+		if wh.IncidentRecurrencePolicy == RECURRENCE_POLICY_REFERENCE {
+			comment := "The problem returned, new incident: " + lastIncidentIds(models.RecurrenceItem{LastIncidents: destinationItems})
+			for _, previous := range recurrence.LastIncidents {
+				destination, err := wh.destinations.Get(previous.Destination)
+				if err != nil {
+					continue
+				}
+				err = destination.Provider.AddIncidentComment(incidents.IncidentRef{Id: previous.IncidentId, PolicyId: previous.PolicyId}, comment)
+				if err != nil {
+					fmt.Println("WARN Failed to comment on previous incident at " + previous.Destination + ": " + incidentName + " incident ID " + previous.IncidentId + " " + err.Error())
+				}
+			}
+		}
+	}
+
+	recurrence.Incidents++
+	recurrence.LastIncidents = destinationItems
+	recurrence.LastOpenedAt = now.Unix()
+	recurrence.LastRecoveredAt = 0
+
+	_, err := wh.dbClient.UpdateRecurrenceItem(*recurrence)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update recurrence item: %s ID %d %s", incidentName, recurrence.Id, err.Error()))
+	}
+	return err
+}
+
+// Note the recovery of the problem of the event item, a new incident within the recurrence window is a recurrence.
+// Caller must hold the database lock.
+func (wh *webHandler) recordRecovery(incidentName string, item models.EventItem) {
+	recurrence, err := wh.findRecurrenceItem(item)
+	if err != nil || recurrence == nil {
+		return
+	}
+
+	recurrence.LastRecoveredAt = time.Now().Unix()
+	_, err = wh.dbClient.UpdateRecurrenceItem(*recurrence)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update recurrence item: %s ID %d %s", incidentName, recurrence.Id, err.Error()))
+	}
+}
+
+// Incident history of hosts/services, those recurring most first
+func (wh *webHandler) handleGetRecurrenceItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	recurrences, err := wh.dbClient.GetAllRecurrenceItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.SliceStable(recurrences, func(i, j int) bool {
+		return recurrences[i].Recurrences > recurrences[j].Recurrences
+	})
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(recurrences)
+}
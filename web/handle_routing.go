@@ -0,0 +1,174 @@
+package web
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/routing"
+)
+
+// Fill in the host and service groups of the event from the state lookup of its site, when the notification doesn't send them
+// and routing rules use them. Groups that can't be looked up stay empty, so group rules don't match.
+func (wh *webHandler) lookupEventGroups(event *models.EventItem) {
+	if !wh.Router.UsesGroups() {
+		return
+	}
+
+	needsHostGroups := len(event.NagiosHostGroups) == 0
+	needsServiceGroups := len(event.NagiosServiceGroups) == 0 && event.NagiosProblemType == "SERVICE"
+	if !needsHostGroups && !needsServiceGroups {
+		return
+	}
+
+	nagiosClient, err := wh.nagiosSites.Get(event.NagiosSiteName)
+	if err != nil {
+		return
+	}
+
+	if event.NagiosProblemType == "SERVICE" {
+		serviceState, err := nagiosClient.GetServiceState(event.NagiosProblemHostname, event.NagiosProblemServiceName)
+		if errors.Is(err, nagios.ErrStateUnsupported) {
+			return
+		}
+		if err != nil {
+			fmt.Println("WARN Failed to get service groups for routing: " + event.NagiosProblemHostname + " " + event.NagiosProblemServiceName + " " + err.Error())
+		}
+		if needsServiceGroups {
+			event.NagiosServiceGroups = serviceState.Groups
+		}
+		// not every site lists the host groups of a service
+		if needsHostGroups && len(serviceState.HostGroups) > 0 {
+			event.NagiosHostGroups = serviceState.HostGroups
+			needsHostGroups = false
+		}
+	}
+
+	if needsHostGroups {
+		hostState, err := nagiosClient.GetHostState(event.NagiosProblemHostname)
+		if err != nil && !errors.Is(err, nagios.ErrStateUnsupported) {
+			fmt.Println("WARN Failed to get host groups for routing: " + event.NagiosProblemHostname + " " + err.Error())
+		}
+		event.NagiosHostGroups = hostState.Groups
+	}
+}
+
+// Fill in the policy and destinations of the event from the routing rules, when the notification doesn't send them.
+// Notifications for an open incident keep the policy it was opened with, as their plugin output may no longer match the same rule.
+// Caller must hold the database lock.
+func (wh *webHandler) routeEvent(event *models.EventItem) error {
+	if event.BetterStackPolicyId == "" {
+		openItem, err := wh.findOpenEventItem(*event)
+		if err != nil {
+			return err
+		}
+		if openItem != nil {
+			event.BetterStackPolicyId = openItem.BetterStackPolicyId
+		}
+	}
+
+	wh.lookupEventGroups(event)
+
+	rule, _ := wh.Router.Route(*event)
+	if rule == nil {
+		return nil
+	}
+
+	if event.BetterStackPolicyId == "" {
+		event.BetterStackPolicyId = rule.PolicyId
+	}
+	if len(event.IncidentDestinations) == 0 {
+		event.IncidentDestinations = rule.Destinations
+	}
+
+	return nil
+}
+
+func (wh *webHandler) handleGetRoutingRules(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(wh.Router.Rules())
+}
+
+type routingMatchResponse struct {
+	Matched   bool          `json:"matched"`
+	RuleIndex int           `json:"ruleIndex"`
+	Rule      *routing.Rule `json:"rule,omitempty"`
+	// policy the incident would be opened with, after the policy id override of the event
+	PolicyId     string   `json:"policyId"`
+	Destinations []string `json:"destinations"`
+	// incident text after templates, rendered with live Nagios data
+	IncidentName        string `json:"incidentName"`
+	IncidentSummary     string `json:"incidentSummary"`
+	IncidentDescription string `json:"incidentDescription"`
+	// on-call channels and team wait for the current Nagios state of the host/service
+	Call     bool `json:"call"`
+	Sms      bool `json:"sms"`
+	Email    bool `json:"email"`
+	Push     bool `json:"push"`
+	TeamWait int  `json:"teamWait"`
+}
+
+// Show which routing rule a sample notification would match, without acting on it
+func (wh *webHandler) handleRoutingMatch(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	var event models.EventItem
+	err := json.NewDecoder(r.Body).Decode(&event)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	incidentName := identifyEvent(&event)
+
+	wh.lookupEventGroups(&event)
+	rule, ruleIndex := wh.Router.Route(event)
+
+	response := routingMatchResponse{
+		Matched:      rule != nil,
+		RuleIndex:    ruleIndex,
+		Rule:         rule,
+		PolicyId:     event.BetterStackPolicyId,
+		Destinations: event.IncidentDestinations,
+	}
+
+	if rule != nil {
+		if response.PolicyId == "" {
+			response.PolicyId = rule.PolicyId
+		}
+		if len(response.Destinations) == 0 {
+			response.Destinations = rule.Destinations
+		}
+	}
+
+	if len(response.Destinations) == 0 {
+		response.Destinations = wh.destinations.Defaults()
+	}
+
+	incident := incidents.Incident{
+		Name:        incidentName,
+		Summary:     event.NagiosProblemContent,
+		Description: event.NagiosProblemContent,
+	}
+	wh.applyIncidentChannels(incidentName, event, rule, &incident)
+	wh.applyIncidentTemplates(incidentName, event, rule, &incident)
+	response.IncidentName = incident.Name
+	response.IncidentSummary = incident.Summary
+	response.IncidentDescription = incident.Description
+	response.Call = incident.Call
+	response.Sms = incident.Sms
+	response.Email = incident.Email
+	response.Push = incident.Push
+	response.TeamWait = incident.TeamWait
+
+	fmt.Println(fmt.Sprintf("INFO Routing match test for %s %s matched rule %d", event.NagiosProblemHostname, event.NagiosProblemServiceName, ruleIndex))
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
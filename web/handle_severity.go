@@ -0,0 +1,117 @@
+package web
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	// comment on the open incident about the state change
+	ESCALATION_POLICY_COMMENT = "comment"
+	// resolve the open incident, and open a new one for the new state, so its on-call channels apply
+	ESCALATION_POLICY_RECREATE = "recreate"
+)
+
+// Severity of a Nagios state, higher is worse. States that aren't problems, or not recognized, rank lowest.
+// UNKNOWN ranks below WARNING: it means the check couldn't tell, usually from a plugin or configuration error,
+// so a WARNING or CRITICAL after it escalates, and UNKNOWN after them de-escalates.
+// UNREACHABLE ranks below DOWN, as the host is hidden behind a parent that's down.
+func stateRank(state string) int {
+	switch state {
+	case "UNKNOWN":
+		return 1
+	case "WARNING", "UNREACHABLE":
+		return 2
+	case "CRITICAL", "DOWN":
+		return 3
+	}
+	return 0
+}
+
+// Whether a PROBLEM notification for the event should open an incident, according to its state.
+// Events without a state are always paged, as notifications from older scripts don't send one.
+// OK and UP are never paged, e.g. for a FLAPPINGSTART sent while the host/service is up.
+func (wh *webHandler) isPagedState(event models.EventItem) (bool, string) {
+	if event.NagiosProblemState == "OK" || event.NagiosProblemState == "UP" {
+		return false, "state " + event.NagiosProblemState + " isn't a problem"
+	}
+
+	if event.NagiosProblemStateType == "SOFT" && !wh.NagiosPageSoftStates {
+		return false, "soft state " + event.NagiosProblemState + " isn't paged"
+	}
+
+	if event.NagiosProblemState != "" && len(wh.NagiosPageStates) > 0 && !slices.Contains(wh.NagiosPageStates, event.NagiosProblemState) {
+		return false, "state " + event.NagiosProblemState + " isn't paged"
+	}
+
+	return true, ""
+}
+
+// Handle a repeat PROBLEM notification whose state differs from the open incident, e.g. a WARNING that became CRITICAL.
+// Caller must hold the database lock.
+func (wh *webHandler) handleStateChange(incidentName string, item, event models.EventItem) error {
+	escalated := stateRank(event.NagiosProblemState) > stateRank(item.NagiosProblemState)
+
+	decision := DECISION_DEESCALATED
+	policy := wh.NagiosDeescalationPolicy
+	if escalated {
+		decision = DECISION_ESCALATED
+		policy = wh.NagiosEscalationPolicy
+	}
+
+	reason := fmt.Sprintf("state changed from %s to %s", item.NagiosProblemState, event.NagiosProblemState)
+	fmt.Println("INFO Nagios " + reason + ": " + incidentName)
+
+	if policy == ESCALATION_POLICY_RECREATE {
+		members, err := wh.getGroupMembers(item)
+		if err != nil {
+			return err
+		}
+
+		// the incident is replaced even when resolving it failed, which is logged
+		children, _ := wh.recoverEventItem(incidentName, item, "")
+
+		// a problem that dropped to a state that isn't paged keeps no incident
+		paged, notPagedReason := wh.isPagedState(event)
+		incidentId := ""
+		if paged {
+			incidentId, err = wh.openProblemIncident(incidentName, event)
+		}
+
+		switch {
+		case err != nil:
+			fmt.Println("ERROR Failed to recreate incident for state change: " + incidentName + " " + err.Error())
+		case !paged:
+			wh.recordDecision(event, item.BetterStackIncidentId, decision, reason+", resolved as "+notPagedReason)
+		case incidentId != "":
+			wh.recordDecision(event, incidentId, decision, reason+", incident recreated")
+		default:
+			// attached to another incident or held back, which is recorded as well
+			wh.recordDecision(event, item.BetterStackIncidentId, decision, reason+", incident resolved")
+		}
+
+		// problems that were attached to the resolved incident and persist get attached to the new one, or their own
+		wh.releaseCorrelatedChildren(incidentName, children)
+		wh.releaseGroupMembers(incidentName, item, members)
+		return err
+	}
+
+	err := wh.commentIncidents(incidentName, item, "Nagios "+reason+": "+event.NagiosProblemContent)
+	if err != nil {
+		fmt.Println("WARN Failed to comment on incident for state change: " + incidentName + " incident ID " + item.BetterStackIncidentId + " " + err.Error())
+	}
+
+	item.NagiosProblemState = event.NagiosProblemState
+	item.NagiosProblemStateType = event.NagiosProblemStateType
+	item.NagiosProblemContent = event.NagiosProblemContent
+	_, err = wh.dbClient.UpdateEventItem(item)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update event item: %s ID %d %s", incidentName, item.Id, err.Error()))
+		return err
+	}
+
+	wh.recordDecision(event, item.BetterStackIncidentId, decision, reason+", incident commented on")
+	return nil
+}
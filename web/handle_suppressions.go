@@ -0,0 +1,422 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	RECURRENCE_DAILY  = "daily"
+	RECURRENCE_WEEKLY = "weekly"
+)
+
+// Check the matchers and times of a suppression rule
+func validateSuppression(suppression models.SuppressionItem) error {
+	if !suppression.Global &&
+		suppression.NagiosSiteName == "" &&
+		suppression.HostPattern == "" &&
+		suppression.ServicePattern == "" &&
+		suppression.BetterStackPolicyId == "" {
+		return fmt.Errorf("suppression without matchers matches every problem, set \"global\" to true to allow it")
+	}
+
+	for _, pattern := range []string{suppression.HostPattern, suppression.ServicePattern} {
+		_, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return err
+		}
+	}
+
+	if suppression.EndsAt != 0 && suppression.EndsAt <= suppression.StartsAt {
+		return fmt.Errorf("endsAt must be after startsAt")
+	}
+
+	switch suppression.Recurrence {
+	case "":
+	case RECURRENCE_DAILY, RECURRENCE_WEEKLY:
+		if suppression.StartsAt == 0 || suppression.EndsAt == 0 {
+			return fmt.Errorf("recurring suppressions need startsAt and endsAt")
+		}
+		if time.Duration(suppression.EndsAt-suppression.StartsAt)*time.Second > recurrencePeriod(suppression.Recurrence) {
+			return fmt.Errorf("suppression must be shorter than its recurrence")
+		}
+	default:
+		return fmt.Errorf("recurrence must be one of: \"\" %s %s", RECURRENCE_DAILY, RECURRENCE_WEEKLY)
+	}
+
+	return nil
+}
+
+func recurrencePeriod(recurrence string) time.Duration {
+	if recurrence == RECURRENCE_WEEKLY {
+		return 7 * 24 * time.Hour
+	}
+	return 24 * time.Hour
+}
+
+// Whether the suppression rule is in effect at the time
+func suppressionActive(suppression models.SuppressionItem, now time.Time) bool {
+	if suppression.StartsAt != 0 && now.Unix() < suppression.StartsAt {
+		return false
+	}
+
+	if suppression.Recurrence == "" {
+		return suppression.EndsAt == 0 || now.Unix() < suppression.EndsAt
+	}
+
+	if suppression.RecursUntil != 0 && now.Unix() >= suppression.RecursUntil {
+		return false
+	}
+
+	// occurrences keep the local time of day of the first one across daylight saving changes
+	start := time.Unix(suppression.StartsAt, 0)
+	duration := time.Duration(suppression.EndsAt-suppression.StartsAt) * time.Second
+	days := int(recurrencePeriod(suppression.Recurrence).Hours() / 24)
+	occurrence := int(now.Sub(start).Hours()/24) / days
+
+	for _, n := range []int{occurrence - 1, occurrence, occurrence + 1} {
+		if n < 0 {
+			continue
+		}
+		occurrenceStart := start.AddDate(0, 0, n*days)
+		if !now.Before(occurrenceStart) && now.Before(occurrenceStart.Add(duration)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Whether the matchers of the suppression rule match the event
+func suppressionMatches(suppression models.SuppressionItem, event models.EventItem) bool {
+	if suppression.NagiosSiteName != "" && suppression.NagiosSiteName != event.NagiosSiteName {
+		return false
+	}
+
+	if suppression.BetterStackPolicyId != "" && suppression.BetterStackPolicyId != event.BetterStackPolicyId {
+		return false
+	}
+
+	if suppression.HostPattern != "" {
+		matched, err := regexp.MatchString("^(?:"+suppression.HostPattern+")$", event.NagiosProblemHostname)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	if suppression.ServicePattern != "" {
+		if event.NagiosProblemType != "SERVICE" {
+			return false
+		}
+		matched, err := regexp.MatchString("^(?:"+suppression.ServicePattern+")$", event.NagiosProblemServiceName)
+		if err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Find the active suppression rule matching the event, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findSuppression(event models.EventItem) (*models.SuppressionItem, error) {
+	suppressions, err := wh.dbClient.GetAllSuppressionItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all suppression items: " + err.Error())
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, suppression := range suppressions {
+		if suppressionActive(suppression, now) && suppressionMatches(suppression, event) {
+			return &suppression, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Record a notification suppressed by a suppression rule.
+// Caller must hold the database lock.
+func (wh *webHandler) recordSuppression(event models.EventItem, suppression models.SuppressionItem) {
+	reason := fmt.Sprintf("suppression rule %d \"%s\"", suppression.Id, suppression.Name)
+	_, err := wh.dbClient.CreateDecisionItem(models.DecisionItem{
+		NagiosSiteName:                event.NagiosSiteName,
+		NagiosProblemType:             event.NagiosProblemType,
+		NagiosProblemHostname:         event.NagiosProblemHostname,
+		NagiosProblemServiceName:      event.NagiosProblemServiceName,
+		NagiosProblemNotificationType: event.NagiosProblemNotificationType,
+		Decision:                      DECISION_SUPPRESSED,
+		Reason:                        reason,
+		SuppressionItemId:             suppression.Id,
+		CreatedAt:                     time.Now().Unix(),
+	})
+	if err != nil {
+		fmt.Println("ERROR Failed to record decision " + DECISION_SUPPRESSED + " (" + reason + "): " + err.Error())
+	}
+}
+
+// Remember a problem suppressed by the rule, so it is brought in line with Nagios when the rule ends.
+// Caller must hold the database lock.
+func (wh *webHandler) attachToSuppression(incidentName string, event models.EventItem, suppression models.SuppressionItem) {
+	events := []models.EventItem{}
+	for _, attached := range suppression.Events {
+		if !sameObject(attached, event) {
+			events = append(events, attached)
+		}
+	}
+	suppression.Events = append(events, event)
+
+	_, err := wh.dbClient.UpdateSuppressionItem(suppression)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("ERROR Failed to update suppression item: %s ID %d %s", incidentName, suppression.Id, err.Error()))
+	}
+}
+
+// Bring the problems the rule suppressed in line with their Nagios state, once the rule no longer suppresses them
+// because it ended, or was changed or deleted. Problems it still suppresses stay attached to it.
+// Caller must hold the database lock.
+func (wh *webHandler) releaseSuppressedProblems(suppression models.SuppressionItem, deleted bool, now time.Time) {
+	active := !deleted && suppressionActive(suppression, now)
+
+	kept := []models.EventItem{}
+	released := []models.EventItem{}
+	for _, event := range suppression.Events {
+		if active && suppressionMatches(suppression, event) {
+			kept = append(kept, event)
+		} else {
+			released = append(released, event)
+		}
+	}
+	if len(released) == 0 {
+		return
+	}
+
+	if !deleted {
+		suppression.Events = kept
+		_, err := wh.dbClient.UpdateSuppressionItem(suppression)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to update suppression item: ID %d %s", suppression.Id, err.Error()))
+			return
+		}
+	}
+
+	cause := fmt.Sprintf("suppression rule %d \"%s\" ended", suppression.Id, suppression.Name)
+	for _, event := range released {
+		incidentName := identifyEvent(&event)
+		err := wh.reconcileWithNagiosState(incidentName, event, event.BetterStackPolicyId, cause)
+		if err != nil {
+			fmt.Println("ERROR Failed to reconcile problem after suppression: " + incidentName + " " + err.Error())
+		}
+	}
+}
+
+// Suppression windows end without a notification, check for ended rules with suppressed problems periodically
+func (wh *webHandler) startSuppressionRoutine() {
+	go func() {
+		for {
+			time.Sleep(time.Second * 15)
+			func() {
+				wh.dbClient.Lock()
+				defer wh.dbClient.Unlock()
+
+				suppressions, err := wh.dbClient.GetAllSuppressionItems()
+				if err != nil {
+					fmt.Println("ERROR Failed to get all suppression items: " + err.Error())
+					return
+				}
+
+				now := time.Now()
+				for _, suppression := range suppressions {
+					wh.releaseSuppressedProblems(suppression, false, now)
+				}
+			}()
+		}
+	}()
+}
+
+type suppressionResponse struct {
+	models.SuppressionItem
+	// whether the rule is in effect right now
+	Active bool `json:"active"`
+}
+
+func (wh *webHandler) handleGetSuppressionItems(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	suppressions, err := wh.dbClient.GetAllSuppressionItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	response := []suppressionResponse{}
+	for _, suppression := range suppressions {
+		response = append(response, suppressionResponse{SuppressionItem: suppression, Active: suppressionActive(suppression, now)})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (wh *webHandler) handleGetSuppressionItem(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid suppression id", http.StatusBadRequest)
+		return
+	}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	suppressions, err := wh.dbClient.GetAllSuppressionItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, suppression := range suppressions {
+		if suppression.Id == id {
+			w.WriteHeader(http.StatusOK)
+			json.NewEncoder(w).Encode(suppressionResponse{SuppressionItem: suppression, Active: suppressionActive(suppression, time.Now())})
+			return
+		}
+	}
+
+	http.Error(w, "Suppression not found", http.StatusNotFound)
+}
+
+func (wh *webHandler) handleCreateSuppressionItem(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	var suppression models.SuppressionItem
+	err := json.NewDecoder(r.Body).Decode(&suppression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = validateSuppression(suppression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	suppression.CreatedAt = time.Now().Unix()
+	suppression.Events = []models.EventItem{}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	suppression.Id, err = wh.dbClient.CreateSuppressionItem(suppression)
+	if err != nil {
+		fmt.Println("ERROR Failed to create suppression item: " + suppression.Name + " " + err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Println(fmt.Sprintf("INFO Created suppression ID %d: %s", suppression.Id, suppression.Name))
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(suppressionResponse{SuppressionItem: suppression, Active: suppressionActive(suppression, time.Now())})
+}
+
+func (wh *webHandler) handleUpdateSuppressionItem(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid suppression id", http.StatusBadRequest)
+		return
+	}
+
+	var suppression models.SuppressionItem
+	err = json.NewDecoder(r.Body).Decode(&suppression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err = validateSuppression(suppression)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	suppressions, err := wh.dbClient.GetAllSuppressionItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, existing := range suppressions {
+		if existing.Id != id {
+			continue
+		}
+
+		suppression.Id = id
+		suppression.CreatedAt = existing.CreatedAt
+		suppression.Events = existing.Events
+		_, err = wh.dbClient.UpdateSuppressionItem(suppression)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to update suppression item: ID %d %s", id, err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Println(fmt.Sprintf("INFO Updated suppression ID %d: %s", id, suppression.Name))
+		wh.releaseSuppressedProblems(suppression, false, time.Now())
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(suppressionResponse{SuppressionItem: suppression, Active: suppressionActive(suppression, time.Now())})
+		return
+	}
+
+	http.Error(w, "Suppression not found", http.StatusNotFound)
+}
+
+func (wh *webHandler) handleDeleteSuppressionItem(w http.ResponseWriter, r *http.Request) {
+	logRequest(r)
+
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid suppression id", http.StatusBadRequest)
+		return
+	}
+
+	wh.dbClient.Lock()
+	defer wh.dbClient.Unlock()
+	suppressions, err := wh.dbClient.GetAllSuppressionItems()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	for _, suppression := range suppressions {
+		if suppression.Id != id {
+			continue
+		}
+
+		_, err = wh.dbClient.DeleteSuppressionItem(id)
+		if err != nil {
+			fmt.Println(fmt.Sprintf("ERROR Failed to delete suppression item: ID %d %s", id, err.Error()))
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		fmt.Println(fmt.Sprintf("INFO Deleted suppression ID %d", id))
+		wh.releaseSuppressedProblems(suppression, true, time.Now())
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	http.Error(w, "Suppression not found", http.StatusNotFound)
+}
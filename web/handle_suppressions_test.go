@@ -0,0 +1,191 @@
+package web
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+// Run the test with the local time zone set to one with daylight saving time
+func useLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local := time.Local
+	time.Local = location
+	t.Cleanup(func() { time.Local = local })
+
+	return location
+}
+
+func TestSuppressionActive(t *testing.T) {
+	// daylight saving time starts on 2025-03-30 and ends on 2025-10-26 in Amsterdam
+	amsterdam := useLocation(t, "Europe/Amsterdam")
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2025, month, day, hour, minute, 0, 0, amsterdam)
+	}
+
+	// every night from 22:00 to 02:00, starting Thursday 2025-03-20
+	daily := models.SuppressionItem{
+		StartsAt:   at(time.March, 20, 22, 0).Unix(),
+		EndsAt:     at(time.March, 21, 2, 0).Unix(),
+		Recurrence: RECURRENCE_DAILY,
+	}
+	dailyUntil := daily
+	dailyUntil.RecursUntil = at(time.March, 24, 0, 0).Unix()
+
+	// Friday 23:00 to Saturday 01:00, starting Friday 2025-03-21
+	weekly := models.SuppressionItem{
+		StartsAt:   at(time.March, 21, 23, 0).Unix(),
+		EndsAt:     at(time.March, 22, 1, 0).Unix(),
+		Recurrence: RECURRENCE_WEEKLY,
+	}
+	// Saturday 01:00 to 04:00 across the end of daylight saving time, starting Saturday 2025-10-18
+	weeklyAutumn := models.SuppressionItem{
+		StartsAt:   at(time.October, 18, 1, 0).Unix(),
+		EndsAt:     at(time.October, 18, 4, 0).Unix(),
+		Recurrence: RECURRENCE_WEEKLY,
+	}
+
+	once := models.SuppressionItem{
+		StartsAt: at(time.March, 20, 22, 0).Unix(),
+		EndsAt:   at(time.March, 21, 2, 0).Unix(),
+	}
+	forever := models.SuppressionItem{}
+
+	tests := []struct {
+		name        string
+		suppression models.SuppressionItem
+		now         time.Time
+		want        bool
+	}{
+		{"daily before the first occurrence", daily, at(time.March, 20, 21, 59), false},
+		{"daily first occurrence", daily, at(time.March, 20, 22, 0), true},
+		{"daily before midnight", daily, at(time.March, 25, 23, 0), true},
+		{"daily after midnight", daily, at(time.March, 26, 1, 59), true},
+		{"daily end is exclusive", daily, at(time.March, 26, 2, 0), false},
+		{"daily during the day", daily, at(time.March, 26, 12, 0), false},
+		{"daily occurrence across the daylight saving change", daily, at(time.March, 29, 23, 30), true},
+		{"daily after the daylight saving change keeps the local start", daily, at(time.April, 1, 22, 0), true},
+		{"daily after the daylight saving change before the local start", daily, at(time.April, 1, 21, 59), false},
+		{"daily after the daylight saving change keeps the local end", daily, at(time.April, 2, 1, 59), true},
+		{"daily after the daylight saving change after the local end", daily, at(time.April, 2, 2, 0), false},
+		{"recurs until, occurrence before", dailyUntil, at(time.March, 22, 23, 0), true},
+		{"recurs until, occurrence after", dailyUntil, at(time.March, 25, 23, 0), false},
+		{"weekly on the day before midnight", weekly, at(time.March, 28, 23, 30), true},
+		{"weekly on the next day after midnight", weekly, at(time.March, 29, 0, 30), true},
+		{"weekly after the end", weekly, at(time.March, 29, 1, 30), false},
+		{"weekly on another day", weekly, at(time.April, 3, 23, 30), false},
+		{"weekly after the daylight saving change", weekly, at(time.April, 4, 23, 0), true},
+		{"weekly after the daylight saving change before the local start", weekly, at(time.April, 4, 22, 59), false},
+		{"weekly before the end of daylight saving time", weeklyAutumn, at(time.October, 25, 3, 59), true},
+		{"weekly after the end of daylight saving time", weeklyAutumn, at(time.November, 1, 1, 0), true},
+		{"weekly after the end of daylight saving time after the local end", weeklyAutumn, at(time.November, 1, 4, 0), false},
+		{"once during", once, at(time.March, 21, 1, 0), true},
+		{"once after", once, at(time.March, 22, 1, 0), false},
+		{"without start or end", forever, at(time.March, 21, 1, 0), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := suppressionActive(test.suppression, test.now)
+			if got != test.want {
+				t.Errorf("suppressionActive at %s = %v, want %v", test.now, got, test.want)
+			}
+		})
+	}
+}
+
+func TestSuppressionMatches(t *testing.T) {
+	hostEvent := models.EventItem{
+		NagiosSiteName:        "site-a",
+		NagiosProblemType:     "HOST",
+		NagiosProblemHostname: "db-1",
+		BetterStackPolicyId:   "policy-1",
+	}
+	serviceEvent := models.EventItem{
+		NagiosSiteName:           "site-a",
+		NagiosProblemType:        "SERVICE",
+		NagiosProblemHostname:    "db-1",
+		NagiosProblemServiceName: "postgres replication",
+		BetterStackPolicyId:      "policy-1",
+	}
+
+	tests := []struct {
+		name        string
+		suppression models.SuppressionItem
+		event       models.EventItem
+		want        bool
+	}{
+		{"no matchers", models.SuppressionItem{Global: true}, hostEvent, true},
+		{"site", models.SuppressionItem{NagiosSiteName: "site-a"}, hostEvent, true},
+		{"other site", models.SuppressionItem{NagiosSiteName: "site-b"}, hostEvent, false},
+		{"policy", models.SuppressionItem{BetterStackPolicyId: "policy-1"}, serviceEvent, true},
+		{"other policy", models.SuppressionItem{BetterStackPolicyId: "policy-2"}, serviceEvent, false},
+		{"host pattern", models.SuppressionItem{HostPattern: "db-.*"}, hostEvent, true},
+		{"host pattern is anchored at the start", models.SuppressionItem{HostPattern: "b-1"}, hostEvent, false},
+		{"host pattern is anchored at the end", models.SuppressionItem{HostPattern: "db"}, hostEvent, false},
+		{"host pattern alternatives are anchored", models.SuppressionItem{HostPattern: "web|db"}, hostEvent, false},
+		{"host pattern applies to services", models.SuppressionItem{HostPattern: "db-1"}, serviceEvent, true},
+		{"service pattern", models.SuppressionItem{ServicePattern: "postgres.*"}, serviceEvent, true},
+		{"service pattern is anchored", models.SuppressionItem{ServicePattern: "postgres"}, serviceEvent, false},
+		{"service pattern never matches a host", models.SuppressionItem{ServicePattern: ".*"}, hostEvent, false},
+		{"host and service pattern", models.SuppressionItem{HostPattern: "db-.*", ServicePattern: "postgres.*"}, serviceEvent, true},
+		{"host and service pattern, other host", models.SuppressionItem{HostPattern: "web-.*", ServicePattern: "postgres.*"}, serviceEvent, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := suppressionMatches(test.suppression, test.event)
+			if got != test.want {
+				t.Errorf("suppressionMatches = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateSuppression(t *testing.T) {
+	start := time.Date(2025, time.March, 20, 22, 0, 0, 0, time.UTC).Unix()
+	hour := int64(time.Hour / time.Second)
+
+	tests := []struct {
+		name        string
+		suppression models.SuppressionItem
+		wantErr     bool
+	}{
+		{"no matchers", models.SuppressionItem{}, true},
+		{"no matchers, not global", models.SuppressionItem{StartsAt: start, EndsAt: start + hour}, true},
+		{"no matchers, global", models.SuppressionItem{Global: true}, false},
+		{"site", models.SuppressionItem{NagiosSiteName: "site-a"}, false},
+		{"host pattern", models.SuppressionItem{HostPattern: "db-.*"}, false},
+		{"service pattern", models.SuppressionItem{ServicePattern: "postgres.*"}, false},
+		{"policy", models.SuppressionItem{BetterStackPolicyId: "policy-1"}, false},
+		{"invalid host pattern", models.SuppressionItem{HostPattern: "db-("}, true},
+		{"invalid service pattern", models.SuppressionItem{ServicePattern: "["}, true},
+		{"end before start", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start - hour}, true},
+		{"end at start", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start}, true},
+		{"daily", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start + 4*hour, Recurrence: RECURRENCE_DAILY}, false},
+		{"daily without end", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, Recurrence: RECURRENCE_DAILY}, true},
+		{"daily longer than a day", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start + 25*hour, Recurrence: RECURRENCE_DAILY}, true},
+		{"weekly longer than a day", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start + 25*hour, Recurrence: RECURRENCE_WEEKLY}, false},
+		{"unknown recurrence", models.SuppressionItem{HostPattern: "db-.*", StartsAt: start, EndsAt: start + hour, Recurrence: "monthly"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := validateSuppression(test.suppression)
+			if test.wantErr && err == nil {
+				t.Error("expected an error")
+			}
+			if !test.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
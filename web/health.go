@@ -1,6 +1,7 @@
 package web
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
 )
 
 const (
@@ -43,9 +45,15 @@ func (nss *nbscServiceStatus) NewSuccess(message string) {
 }
 
 type nbscStatus struct {
-	Database    nbscServiceStatus
-	Nagios      nbscServiceStatus
-	BetterStack nbscServiceStatus
+	Database  nbscServiceStatus
+	Nagios    nbscServiceStatus
+	Incidents nbscServiceStatus
+}
+
+func (ns nbscStatus) Healthy() bool {
+	return ns.Database.State != UNHEALTHY &&
+		ns.Nagios.State != UNHEALTHY &&
+		ns.Incidents.State != UNHEALTHY
 }
 
 func (wh *webHandler) startHealthRoutine() {
@@ -71,17 +79,17 @@ Nagios: {{.Nagios.State}}
 {{if .Succeeded}}  - SUCCESS: {{else}}  - FAILURE: {{end}}{{.Message}}
 {{- end}}
 
-BetterStack: {{.BetterStack.State}}
-{{- range .BetterStack.CheckStates}}
+Incidents: {{.Incidents.State}}
+{{- range .Incidents.CheckStates}}
 {{if .Succeeded}}  - SUCCESS: {{else}}  - FAILURE: {{end}}{{.Message}}
 {{- end}}
 `
 
 func (wh *webHandler) updateHealthStatus() {
 	connectorStatus := nbscStatus{
-		Database:    newNbscServiceStatus(),
-		Nagios:      newNbscServiceStatus(),
-		BetterStack: newNbscServiceStatus(),
+		Database:  newNbscServiceStatus(),
+		Nagios:    newNbscServiceStatus(),
+		Incidents: newNbscServiceStatus(),
 	}
 
 	// check database
@@ -115,11 +123,44 @@ func (wh *webHandler) updateHealthStatus() {
 	}
 
 	// check nagios
-	hosts, err := wh.nagiosClient.GetHosts()
+	for _, siteName := range wh.nagiosSites.Names() {
+		nagiosClient, err := wh.nagiosSites.Get(siteName)
+		if err != nil {
+			connectorStatus.Nagios.NewFailure("Failed to get Nagios site " + siteName + ": " + err.Error())
+			continue
+		}
+		checkNagiosSite(&connectorStatus.Nagios, siteName, nagiosClient)
+	}
+
+	// check incident destinations
+	for _, destinationName := range wh.destinations.Names() {
+		destination, err := wh.destinations.Get(destinationName)
+		if err != nil {
+			connectorStatus.Incidents.NewFailure("Failed to get incident destination " + destinationName + ": " + err.Error())
+			continue
+		}
+		err = destination.Provider.CheckConnection()
+		if err != nil {
+			connectorStatus.Incidents.NewFailure("Failed to check " + destinationName + " (" + destination.Provider.Name() + ") connection: " + err.Error())
+		} else {
+			connectorStatus.Incidents.NewSuccess("Successfully checked " + destinationName + " (" + destination.Provider.Name() + ") connection")
+		}
+	}
+
+	wh.healthStatus = connectorStatus
+	fmt.Println("updated health status")
+}
+
+func checkNagiosSite(status *nbscServiceStatus, siteName string, nagiosClient nagios.MonitoringSource) {
+	hosts, err := nagiosClient.GetHosts()
+	if errors.Is(err, nagios.ErrStateUnsupported) {
+		status.NewSuccess("Nagios site " + siteName + " only accepts commands, skipping state checks")
+		return
+	}
 	if err != nil {
-		connectorStatus.Nagios.NewFailure("Failed to get hosts from Nagios: " + err.Error())
+		status.NewFailure("Failed to get hosts from Nagios site " + siteName + ": " + err.Error())
 	} else {
-		connectorStatus.Nagios.NewSuccess("Successfully got hosts from Nagios")
+		status.NewSuccess("Successfully got hosts from Nagios site " + siteName)
 	}
 
 	// pick a random host
@@ -134,37 +175,28 @@ func (wh *webHandler) updateHealthStatus() {
 		serviceName := host.Services[rand.Intn(len(host.Services))]
 
 		// check service
-		service, err := wh.nagiosClient.GetServiceState(host.DisplayName, serviceName)
+		service, err := nagiosClient.GetServiceState(host.DisplayName, serviceName)
 		if err != nil {
-			connectorStatus.Nagios.NewFailure(
+			status.NewFailure(
 				fmt.Sprintf(
-					`Failed to get Nagios service state for HOST="%s" SERVICE="%s": %s`,
+					`Failed to get Nagios site %s service state for HOST="%s" SERVICE="%s": %s`,
+					siteName,
 					host.DisplayName,
 					service.DisplayName,
 					err.Error(),
 				),
 			)
 		} else {
-			connectorStatus.Nagios.NewSuccess(
+			status.NewSuccess(
 				fmt.Sprintf(
-					`Successfully got Nagios service state for HOST="%s" SERVICE="%s"`,
+					`Successfully got Nagios site %s service state for HOST="%s" SERVICE="%s"`,
+					siteName,
 					host.DisplayName,
 					service.DisplayName,
 				),
 			)
 		}
 	}
-
-	// check betterstack
-	err = wh.betterClient.CheckIncidentsEndpoint()
-	if err != nil {
-		connectorStatus.BetterStack.NewFailure("Failed to check BetterStack incidents endpoint: " + err.Error())
-	} else {
-		connectorStatus.BetterStack.NewSuccess("BetterStack incidents endpoint returned status 200")
-	}
-
-	wh.healthStatus = connectorStatus
-	fmt.Println("updated health status")
 }
 
 func (wh *webHandler) handleHealthRequest(w http.ResponseWriter, r *http.Request) {
@@ -184,9 +216,7 @@ func (wh *webHandler) handleHealthRequest(w http.ResponseWriter, r *http.Request
 	wh.healthStatusMutex.Lock()
 	defer wh.healthStatusMutex.Unlock()
 
-	if wh.healthStatus.Database.State == UNHEALTHY ||
-		wh.healthStatus.Nagios.State == UNHEALTHY ||
-		wh.healthStatus.BetterStack.State == UNHEALTHY {
+	if !wh.healthStatus.Healthy() {
 		health = UNHEALTHY
 	}
 
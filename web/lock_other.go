@@ -0,0 +1,12 @@
+//go:build !unix
+
+package web
+
+import (
+	"os"
+)
+
+// Without flock a running server isn't detected, the connector runs on unix
+func lockDatabaseFile(dbPath string) (*os.File, error) {
+	return os.OpenFile(dbPath+".lock", os.O_CREATE|os.O_RDWR, 0o640)
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package web
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Take the exclusive lock of the database at dbPath, held by the server while it runs.
+// Fails with errServerRunning instead of waiting when another process holds it, release it by closing the returned file.
+func lockDatabaseFile(dbPath string) (*os.File, error) {
+	f, err := os.OpenFile(dbPath+".lock", os.O_CREATE|os.O_RDWR, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, errServerRunning
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
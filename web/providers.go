@@ -0,0 +1,106 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkmollman/nagios-better-stack-connector/betterstack"
+	"github.com/pkmollman/nagios-better-stack-connector/chatwebhook"
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/opsgenie"
+	"github.com/pkmollman/nagios-better-stack-connector/pagerduty"
+)
+
+const (
+	INCIDENT_PROVIDER_BETTERSTACK = "betterstack"
+	INCIDENT_PROVIDER_PAGERDUTY   = "pagerduty"
+	INCIDENT_PROVIDER_OPSGENIE    = "opsgenie"
+	INCIDENT_PROVIDER_CHATWEBHOOK = "chatwebhook"
+)
+
+// Environment variable prefix for a named destination, "bs-dba" becomes "DESTINATION_BS_DBA_"
+func destinationEnvPrefix(destinationName string) string {
+	return "DESTINATION_" + envKey(destinationName) + "_"
+}
+
+// Load an incident provider, reading its environment variables with the given prefix
+func loadIncidentProvider(envPrefix, provider string) incidents.IncidentProvider {
+	switch provider {
+	case INCIDENT_PROVIDER_BETTERSTACK:
+		betterStackApiKey := getEnvVarOrPanic(envPrefix + "BETTER_STACK_API_KEY")
+		betterDefaultContactEmail := getEnvVarOrPanic(envPrefix + "BETTER_STACK_DEFAULT_CONTACT_EMAIL")
+
+		return betterstack.NewBetterStackClient(betterStackApiKey, "https://uptime.betterstack.com", betterDefaultContactEmail)
+	case INCIDENT_PROVIDER_PAGERDUTY:
+		pagerDutyApiToken := getEnvVarOrDefault(envPrefix+"PAGERDUTY_API_TOKEN", "")
+		pagerDutyFromEmail := getEnvVarOrDefault(envPrefix+"PAGERDUTY_FROM_EMAIL", "")
+		pagerDutyWebhookSecret := getEnvVarOrDefault(envPrefix+"PAGERDUTY_WEBHOOK_SECRET", "")
+
+		return pagerduty.NewPagerDutyClient("https://events.pagerduty.com", "https://api.pagerduty.com", pagerDutyApiToken, pagerDutyFromEmail, pagerDutyWebhookSecret)
+	case INCIDENT_PROVIDER_OPSGENIE:
+		opsgenieApiKey := getEnvVarOrPanic(envPrefix + "OPSGENIE_API_KEY")
+		opsgenieApiUrl := getEnvVarOrDefault(envPrefix+"OPSGENIE_API_URL", "https://api.opsgenie.com")
+		opsgenieDefaultUser := getEnvVarOrDefault(envPrefix+"OPSGENIE_DEFAULT_USER", "nagios")
+		opsgenieWebhookToken := getEnvVarOrDefault(envPrefix+"OPSGENIE_WEBHOOK_TOKEN", "")
+
+		return opsgenie.NewOpsgenieClient(opsgenieApiKey, opsgenieApiUrl, opsgenieDefaultUser, opsgenieWebhookToken)
+	case INCIDENT_PROVIDER_CHATWEBHOOK:
+		chatWebhookUrl := getEnvVarOrPanic(envPrefix + "CHAT_WEBHOOK_URL")
+
+		return chatwebhook.NewChatWebhookClient(chatWebhookUrl)
+	}
+
+	fmt.Println(envPrefix+"INCIDENT_PROVIDER must be one of:", INCIDENT_PROVIDER_BETTERSTACK, INCIDENT_PROVIDER_PAGERDUTY, INCIDENT_PROVIDER_OPSGENIE, INCIDENT_PROVIDER_CHATWEBHOOK)
+	os.Exit(1)
+	return nil
+}
+
+// Load the destinations from INCIDENT_DESTINATIONS, or fall back to a single destination for INCIDENT_PROVIDER
+func loadIncidentDestinationRegistry() *incidents.DestinationRegistry {
+	registry := incidents.NewDestinationRegistry()
+
+	destinationNames := strings.TrimSpace(os.Getenv("INCIDENT_DESTINATIONS"))
+	if destinationNames == "" {
+		// a single destination named after its provider, like before destinations were configurable
+		provider := getEnvVarOrDefault("INCIDENT_PROVIDER", INCIDENT_PROVIDER_BETTERSTACK)
+		registry.Register(incidents.Destination{
+			Name:     provider,
+			Provider: loadIncidentProvider("", provider),
+		})
+		return registry
+	}
+
+	for _, destinationName := range strings.Split(destinationNames, ",") {
+		destinationName = strings.TrimSpace(destinationName)
+		if destinationName == "" {
+			continue
+		}
+
+		prefix := destinationEnvPrefix(destinationName)
+		provider := getEnvVarOrPanic(prefix + "INCIDENT_PROVIDER")
+
+		registry.Register(incidents.Destination{
+			Name:     destinationName,
+			Provider: loadIncidentProvider(prefix, provider),
+			PolicyId: getEnvVarOrDefault(prefix+"POLICY_ID", ""),
+		})
+		fmt.Println("Configured incident destination", destinationName, "using", provider)
+	}
+
+	defaultDestinations := []string{}
+	for _, destinationName := range strings.Split(os.Getenv("INCIDENT_DEFAULT_DESTINATIONS"), ",") {
+		destinationName = strings.TrimSpace(destinationName)
+		if destinationName != "" {
+			defaultDestinations = append(defaultDestinations, destinationName)
+		}
+	}
+
+	err := registry.SetDefaults(defaultDestinations)
+	if err != nil {
+		fmt.Println("invalid INCIDENT_DEFAULT_DESTINATIONS:", err.Error())
+		os.Exit(1)
+	}
+
+	return registry
+}
@@ -0,0 +1,394 @@
+package web
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+)
+
+const (
+	RATE_LIMIT_SCOPE_GLOBAL = "global"
+	// problem type of the event items holding notification storm incidents
+	STORM_PROBLEM_TYPE = "STORM"
+	// most problem names listed in a storm summary comment
+	STORM_SUMMARY_MAX_NAMES = 20
+)
+
+type rateLimitAttempt struct {
+	At     time.Time
+	Scopes []string
+}
+
+// Limits on incident creation, global and for each site and policy. A limit of 0 disables it.
+// Only accessed with the database lock held.
+type rateLimiter struct {
+	Window          time.Duration
+	Incidents       int
+	SiteIncidents   int
+	PolicyIncidents int
+	// policy of storm incidents, defaults to the policy of the problem that started the storm
+	StormPolicyId string
+	// counters since startup
+	IncidentsCreated      int64
+	NotificationsLimited  int64
+	attempts              []rateLimitAttempt
+	storms                map[string]time.Time
+	stormSuppressed       map[string][]string
+	stormSuppressedTotals map[string]int
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{
+		Window:                time.Minute,
+		storms:                map[string]time.Time{},
+		stormSuppressed:       map[string][]string{},
+		stormSuppressedTotals: map[string]int{},
+	}
+}
+
+func (rl *rateLimiter) enabled() bool {
+	return rl.Incidents > 0 || rl.SiteIncidents > 0 || rl.PolicyIncidents > 0
+}
+
+// Scopes the event counts against
+func (rl *rateLimiter) scopes(event models.EventItem) []string {
+	scopes := []string{}
+	if rl.Incidents > 0 {
+		scopes = append(scopes, RATE_LIMIT_SCOPE_GLOBAL)
+	}
+	if rl.SiteIncidents > 0 {
+		scopes = append(scopes, "site:"+event.NagiosSiteName)
+	}
+	if rl.PolicyIncidents > 0 {
+		scopes = append(scopes, "policy:"+event.BetterStackPolicyId)
+	}
+	return scopes
+}
+
+func (rl *rateLimiter) limit(scope string) int {
+	switch {
+	case scope == RATE_LIMIT_SCOPE_GLOBAL:
+		return rl.Incidents
+	case strings.HasPrefix(scope, "site:"):
+		return rl.SiteIncidents
+	case strings.HasPrefix(scope, "policy:"):
+		return rl.PolicyIncidents
+	}
+	return 0
+}
+
+// Forget attempts that fell out of the window
+func (rl *rateLimiter) prune(now time.Time) {
+	kept := rl.attempts[:0]
+	for _, attempt := range rl.attempts {
+		if now.Sub(attempt.At) < rl.Window {
+			kept = append(kept, attempt)
+		}
+	}
+	rl.attempts = kept
+}
+
+// Incidents that were to be opened in the scope within the window
+func (rl *rateLimiter) count(scope string) int {
+	count := 0
+	for _, attempt := range rl.attempts {
+		for _, attemptScope := range attempt.Scopes {
+			if attemptScope == scope {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// Scopes with attempts in the window or a storm, sorted
+func (rl *rateLimiter) activeScopes() []string {
+	seen := map[string]bool{}
+	if rl.Incidents > 0 {
+		seen[RATE_LIMIT_SCOPE_GLOBAL] = true
+	}
+	for _, attempt := range rl.attempts {
+		for _, scope := range attempt.Scopes {
+			seen[scope] = true
+		}
+	}
+	for scope := range rl.storms {
+		seen[scope] = true
+	}
+
+	scopes := []string{}
+	for scope := range seen {
+		scopes = append(scopes, scope)
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+// Find the event item of the storm incident of the scope, or nil.
+// Caller must hold the database lock.
+func (wh *webHandler) findStormItem(scope string) (*models.EventItem, error) {
+	items, err := wh.dbClient.GetAllEventItems()
+	if err != nil {
+		fmt.Println("ERROR Failed to get all event items: " + err.Error())
+		return nil, err
+	}
+
+	for _, item := range items {
+		if item.NagiosProblemType == STORM_PROBLEM_TYPE && item.NagiosProblemServiceName == scope {
+			return &item, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Count the PROBLEM against the rate limits, and hold back its incident when a scope of it is over the limit.
+// The first problem over the limit opens a notification storm incident for the scope.
+// Returns whether the problem was held back.
+// Caller must hold the database lock.
+func (wh *webHandler) handleRateLimitedProblem(incidentName string, event models.EventItem) (bool, error) {
+	rl := wh.RateLimiter
+	if !rl.enabled() {
+		return false, nil
+	}
+
+	now := time.Now()
+	rl.prune(now)
+
+	scopes := rl.scopes(event)
+	rl.attempts = append(rl.attempts, rateLimitAttempt{At: now, Scopes: scopes})
+
+	limitedScopes := []string{}
+	for _, scope := range scopes {
+		_, storming := rl.storms[scope]
+		if storming {
+			limitedScopes = append(limitedScopes, scope)
+			continue
+		}
+
+		if rl.count(scope) > rl.limit(scope) {
+			err := wh.startStorm(scope, event)
+			if err != nil {
+				return false, err
+			}
+			limitedScopes = append(limitedScopes, scope)
+		}
+	}
+
+	if len(limitedScopes) == 0 {
+		return false, nil
+	}
+
+	stormIncidentId := ""
+	for _, scope := range limitedScopes {
+		rl.stormSuppressed[scope] = append(rl.stormSuppressed[scope], incidentName)
+		rl.stormSuppressedTotals[scope]++
+
+		stormItem, err := wh.findStormItem(scope)
+		if err == nil && stormItem != nil && stormIncidentId == "" {
+			stormIncidentId = stormItem.BetterStackIncidentId
+		}
+	}
+
+	rl.NotificationsLimited++
+	reason := fmt.Sprintf("over the rate limit of %s, notification storm", strings.Join(limitedScopes, ", "))
+	wh.recordDecision(event, stormIncidentId, DECISION_RATE_LIMITED, reason)
+	fmt.Println("INFO Not opening incident, " + reason + ": \"" + incidentName + "\"")
+	return true, nil
+}
+
+// Open the notification storm incident of the scope.
+// Caller must hold the database lock.
+func (wh *webHandler) startStorm(scope string, event models.EventItem) error {
+	rl := wh.RateLimiter
+	stormName := "Notification storm (" + scope + ")"
+
+	policyId := rl.StormPolicyId
+	if policyId == "" {
+		policyId = event.BetterStackPolicyId
+	}
+
+	summary := fmt.Sprintf("More than %d incidents within %d seconds for %s, further incidents are held back until the rate drops", rl.limit(scope), int(rl.Window.Seconds()), scope)
+
+	stormEvent := models.EventItem{
+		NagiosSiteName:                event.NagiosSiteName,
+		NagiosProblemId:               fmt.Sprintf("storm-%d", time.Now().Unix()),
+		NagiosProblemType:             STORM_PROBLEM_TYPE,
+		NagiosProblemServiceName:      scope,
+		NagiosProblemContent:          summary,
+		NagiosProblemNotificationType: "PROBLEM",
+		BetterStackPolicyId:           policyId,
+		IncidentDestinations:          event.IncidentDestinations,
+	}
+
+	incident := incidents.Incident{
+		ProblemKey:  "nbsc:storm:" + scope,
+		PolicyId:    policyId,
+		Name:        stormName,
+		Summary:     summary,
+		Description: summary,
+		Source:      "nbsc",
+		Urgency:     incidents.URGENCY_CRITICAL,
+	}
+
+	fmt.Println("WARN Rate limit exceeded, opening incident: " + stormName)
+	destinationItems, err := wh.openDestinationIncidents(stormName, stormEvent, incident)
+	if err != nil {
+		fmt.Println("ERROR Failed to create incident: " + stormName + " " + err.Error())
+		return err
+	}
+
+	_, err = wh.storeEventItem(stormName, stormEvent, destinationItems)
+	if err != nil {
+		return err
+	}
+
+	rl.storms[scope] = time.Now()
+	return nil
+}
+
+// Comment the problems held back since the last summary on the storm incidents, and resolve the
+// storm incidents of scopes that stayed within their limit for a whole window.
+// Caller must hold the database lock.
+func (wh *webHandler) updateStorms() {
+	rl := wh.RateLimiter
+	now := time.Now()
+	rl.prune(now)
+
+	for scope, since := range rl.storms {
+		stormName := "Notification storm (" + scope + ")"
+
+		stormItem, err := wh.findStormItem(scope)
+		if err != nil {
+			continue
+		}
+		if stormItem == nil {
+			// the storm incident was removed by hand
+			delete(rl.storms, scope)
+			continue
+		}
+
+		suppressed := rl.stormSuppressed[scope]
+		if len(suppressed) > 0 {
+			comment := fmt.Sprintf("%d incident(s) held back: %s", len(suppressed), strings.Join(suppressed[:min(len(suppressed), STORM_SUMMARY_MAX_NAMES)], ", "))
+			if len(suppressed) > STORM_SUMMARY_MAX_NAMES {
+				comment += fmt.Sprintf(" and %d more", len(suppressed)-STORM_SUMMARY_MAX_NAMES)
+			}
+			err = wh.commentIncidents(stormName, *stormItem, comment)
+			if err != nil {
+				fmt.Println("WARN Failed to comment on incident: " + stormName + " incident ID " + stormItem.BetterStackIncidentId + " " + err.Error())
+			}
+			rl.stormSuppressed[scope] = nil
+		}
+
+		if now.Sub(since) < rl.Window || rl.count(scope) > rl.limit(scope) {
+			continue
+		}
+
+		comment := fmt.Sprintf("Rate dropped below the limit, %d incident(s) were held back during the storm. Check Nagios for problems that persist.", rl.stormSuppressedTotals[scope])
+		err = wh.commentIncidents(stormName, *stormItem, comment)
+		if err != nil {
+			fmt.Println("WARN Failed to comment on incident: " + stormName + " incident ID " + stormItem.BetterStackIncidentId + " " + err.Error())
+		}
+
+		err = wh.resolveEventItem(stormName, *stormItem, "")
+		if err != nil {
+			continue
+		}
+
+		fmt.Println("INFO Notification storm ended: " + scope)
+		delete(rl.storms, scope)
+		delete(rl.stormSuppressed, scope)
+		delete(rl.stormSuppressedTotals, scope)
+	}
+}
+
+// Pick up storm incidents open before a restart, and keep updating storms
+func (wh *webHandler) startStormRoutine() {
+	go func() {
+		func() {
+			wh.dbClient.Lock()
+			defer wh.dbClient.Unlock()
+
+			items, err := wh.dbClient.GetAllEventItems()
+			if err != nil {
+				fmt.Println("ERROR Failed to get all event items: " + err.Error())
+				return
+			}
+			for _, item := range items {
+				if item.NagiosProblemType == STORM_PROBLEM_TYPE {
+					// measure the rate for a whole window before ending the storm
+					wh.RateLimiter.storms[item.NagiosProblemServiceName] = time.Now()
+				}
+			}
+		}()
+
+		for {
+			time.Sleep(time.Second * 15)
+			func() {
+				wh.dbClient.Lock()
+				defer wh.dbClient.Unlock()
+				wh.updateStorms()
+			}()
+		}
+	}()
+}
+
+type rateLimitScopeStatus struct {
+	Scope           string `json:"scope"`
+	Limit           int    `json:"limit"`
+	Incidents       int    `json:"incidents"`
+	Storm           bool   `json:"storm"`
+	StormIncidentId string `json:"stormIncidentId,omitempty"`
+	HeldBack        int    `json:"heldBack"`
+}
+
+type rateLimitStatus struct {
+	WindowSeconds        int                    `json:"windowSeconds"`
+	Incidents            int                    `json:"incidents"`
+	SiteIncidents        int                    `json:"siteIncidents"`
+	PolicyIncidents      int                    `json:"policyIncidents"`
+	IncidentsCreated     int64                  `json:"incidentsCreated"`
+	NotificationsLimited int64                  `json:"notificationsLimited"`
+	Scopes               []rateLimitScopeStatus `json:"scopes"`
+}
+
+// Current rate limits, and the incidents counted against them within the window.
+// Caller must hold the database lock.
+func (wh *webHandler) getRateLimitStatus() rateLimitStatus {
+	rl := wh.RateLimiter
+	rl.prune(time.Now())
+
+	status := rateLimitStatus{
+		WindowSeconds:        int(rl.Window.Seconds()),
+		Incidents:            rl.Incidents,
+		SiteIncidents:        rl.SiteIncidents,
+		PolicyIncidents:      rl.PolicyIncidents,
+		IncidentsCreated:     rl.IncidentsCreated,
+		NotificationsLimited: rl.NotificationsLimited,
+		Scopes:               []rateLimitScopeStatus{},
+	}
+
+	for _, scope := range rl.activeScopes() {
+		scopeStatus := rateLimitScopeStatus{
+			Scope:     scope,
+			Limit:     rl.limit(scope),
+			Incidents: rl.count(scope),
+			HeldBack:  rl.stormSuppressedTotals[scope],
+		}
+		if _, storming := rl.storms[scope]; storming {
+			scopeStatus.Storm = true
+			stormItem, err := wh.findStormItem(scope)
+			if err == nil && stormItem != nil {
+				scopeStatus.StormIncidentId = stormItem.BetterStackIncidentId
+			}
+		}
+		status.Scopes = append(status.Scopes, scopeStatus)
+	}
+
+	return status
+}
@@ -0,0 +1,94 @@
+package web
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/cmdfile"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/icinga2"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios/livestatus"
+)
+
+const (
+	SITE_BACKEND_THRUK      = "thruk"
+	SITE_BACKEND_ICINGA2    = "icinga2"
+	SITE_BACKEND_LIVESTATUS = "livestatus"
+	SITE_BACKEND_CMDFILE    = "cmdfile"
+)
+
+// Environment variable prefix for a named site, "some-site" becomes "NAGIOS_SITE_SOME_SITE_"
+func siteEnvPrefix(siteName string) string {
+	return "NAGIOS_SITE_" + envKey(siteName) + "_"
+}
+
+// Load the Nagios sites from NAGIOS_SITES, or fall back to the single site NAGIOS_THRUK_* variables
+func loadNagiosSiteRegistry() *nagios.SiteRegistry {
+	registry := nagios.NewSiteRegistry()
+
+	siteNames := strings.TrimSpace(os.Getenv("NAGIOS_SITES"))
+	if siteNames == "" {
+		nagiosUser := getEnvVarOrPanic("NAGIOS_THRUK_API_USER")
+		nagiosKey := getEnvVarOrPanic("NAGIOS_THRUK_API_KEY")
+		nagiosBaseUrl := getEnvVarOrPanic("NAGIOS_THRUK_BASE_URL")
+		nagiosThrukSiteName := getEnvVarOrPanic("NAGIOS_THRUK_SITE_NAME")
+		// (optional) the site name notifications are sent with, notifications with any other site name are rejected
+		nagiosSiteName := os.Getenv("NAGIOS_SITE_NAME")
+
+		client := nagios.NewNagiosClient(nagiosUser, nagiosKey, nagiosBaseUrl, nagiosThrukSiteName)
+		if nagiosSiteName != "" {
+			registry.Register(nagiosSiteName, client)
+			return registry
+		}
+
+		// without a site name the single site handles notifications from every site name, like before sites were configurable
+		registry.Register(nagiosThrukSiteName, client)
+		registry.SetFallback(client)
+		return registry
+	}
+
+	for _, siteName := range strings.Split(siteNames, ",") {
+		siteName = strings.TrimSpace(siteName)
+		if siteName == "" {
+			continue
+		}
+
+		prefix := siteEnvPrefix(siteName)
+		backend := getEnvVarOrDefault(prefix+"BACKEND", SITE_BACKEND_THRUK)
+
+		switch backend {
+		case SITE_BACKEND_THRUK:
+			nagiosUser := getEnvVarOrPanic(prefix + "THRUK_API_USER")
+			nagiosKey := getEnvVarOrPanic(prefix + "THRUK_API_KEY")
+			nagiosBaseUrl := getEnvVarOrPanic(prefix + "THRUK_BASE_URL")
+			nagiosThrukSiteName := getEnvVarOrDefault(prefix+"THRUK_SITE_NAME", siteName)
+
+			registry.Register(siteName, nagios.NewNagiosClient(nagiosUser, nagiosKey, nagiosBaseUrl, nagiosThrukSiteName))
+		case SITE_BACKEND_ICINGA2:
+			icingaUser := getEnvVarOrPanic(prefix + "ICINGA2_API_USER")
+			icingaPassword := getEnvVarOrPanic(prefix + "ICINGA2_API_PASSWORD")
+			icingaBaseUrl := getEnvVarOrPanic(prefix + "ICINGA2_BASE_URL")
+			icingaInsecureSkipVerify := getEnvVarOrDefault(prefix+"ICINGA2_INSECURE_SKIP_VERIFY", "false") == "true"
+
+			registry.Register(siteName, icinga2.NewIcinga2Client(icingaUser, icingaPassword, icingaBaseUrl, icingaInsecureSkipVerify))
+		case SITE_BACKEND_LIVESTATUS:
+			livestatusAddress := getEnvVarOrPanic(prefix + "LIVESTATUS_ADDRESS")
+			livestatusAuthor := getEnvVarOrDefault(prefix+"LIVESTATUS_AUTHOR", "nbsc")
+
+			registry.Register(siteName, livestatus.NewLivestatusClient(livestatusAddress, livestatusAuthor))
+		case SITE_BACKEND_CMDFILE:
+			commandFilePath := getEnvVarOrDefault(prefix+"COMMAND_FILE_PATH", "/usr/local/nagios/var/rw/nagios.cmd")
+			commandFileAuthor := getEnvVarOrDefault(prefix+"COMMAND_FILE_AUTHOR", "nbsc")
+
+			registry.Register(siteName, cmdfile.NewCommandFileClient(commandFilePath, commandFileAuthor))
+		default:
+			fmt.Println("unknown backend for Nagios site", siteName+":", backend)
+			os.Exit(1)
+		}
+
+		fmt.Println("Configured Nagios site", siteName, "using", backend)
+	}
+
+	return registry
+}
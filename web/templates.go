@@ -0,0 +1,76 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
+	"github.com/pkmollman/nagios-better-stack-connector/models"
+	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/routing"
+)
+
+// Data incident templates are rendered with, all event item fields plus live data of the host/service
+type incidentTemplateData struct {
+	models.EventItem
+	// incident name used without a name template, "[host] - [service]"
+	DefaultName string
+	// live host data from Nagios (IpAddr, Alias, Groups, NotesUrl, ActionUrl), empty if it couldn't be looked up
+	Host nagios.HostState
+	// live service data from Nagios (Groups, NotesUrl, ActionUrl), empty for host problems
+	Service nagios.ServiceState
+}
+
+// Gather template data for the event, looking up the host/service in Nagios
+func (wh *webHandler) getIncidentTemplateData(incidentName string, event models.EventItem) incidentTemplateData {
+	data := incidentTemplateData{
+		EventItem:   event,
+		DefaultName: incidentName,
+	}
+
+	nagiosClient, err := wh.nagiosSites.Get(event.NagiosSiteName)
+	if err != nil {
+		fmt.Println("WARN Failed to get Nagios site for incident templates: " + incidentName + " " + err.Error())
+		return data
+	}
+
+	data.Host, err = nagiosClient.GetHostState(event.NagiosProblemHostname)
+	if err != nil && !errors.Is(err, nagios.ErrStateUnsupported) {
+		fmt.Println("WARN Failed to get host data for incident templates: " + incidentName + " " + err.Error())
+	}
+
+	if event.NagiosProblemType == "SERVICE" {
+		data.Service, err = nagiosClient.GetServiceState(event.NagiosProblemHostname, event.NagiosProblemServiceName)
+		if err != nil && !errors.Is(err, nagios.ErrStateUnsupported) {
+			fmt.Println("WARN Failed to get service data for incident templates: " + incidentName + " " + err.Error())
+		}
+	}
+
+	return data
+}
+
+// Render the global incident templates, then those of the routing rule, into the incident.
+// Templates that fail to render are logged and skipped, an incident with the default text beats no incident.
+func (wh *webHandler) applyIncidentTemplates(incidentName string, event models.EventItem, rule *routing.Rule, incident *incidents.Incident) {
+	templates := []*routing.Templates{wh.IncidentTemplates}
+	if rule != nil {
+		templates = append(templates, &rule.Templates)
+	}
+
+	needsData := false
+	for _, t := range templates {
+		needsData = needsData || t.IsSet()
+	}
+	if !needsData {
+		return
+	}
+
+	data := wh.getIncidentTemplateData(incidentName, event)
+
+	for _, t := range templates {
+		err := t.Apply(incident, data)
+		if err != nil {
+			fmt.Println("WARN Failed to render incident templates: " + incidentName + " " + err.Error())
+		}
+	}
+}
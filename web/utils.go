@@ -1,9 +1,11 @@
 package web
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 )
 
 func getEnvVarOrPanic(key string) string {
@@ -16,6 +18,27 @@ func getEnvVarOrPanic(key string) string {
 	return value
 }
 
+func getEnvVarOrDefault(key, defaultValue string) string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	return value
+}
+
+// Environment variable key for a name, upper cased with anything but letters and digits replaced by "_"
+func envKey(name string) string {
+	key := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+
+	return strings.ToUpper(key)
+}
+
 // Log http request in a friendly format
 func logRequest(r *http.Request) {
 	remoteAddr := r.RemoteAddr
@@ -27,3 +50,29 @@ func logRequest(r *http.Request) {
 
 	fmt.Println(fmt.Sprintf("INFO %s %s %s %s", remoteAddr, r.Method, r.URL, r.Proto))
 }
+
+// Whether the request carries the bearer token in its Authorization header
+func hasBearerToken(r *http.Request, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+}
+
+// Wrap an endpoint that changes Nagios or connector state, so it requires the ADMIN_API_TOKEN bearer token.
+// Without a token configured the endpoint is disabled.
+func (wh *webHandler) requireAdminToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if wh.AdminApiToken == "" {
+			logRequest(r)
+			http.Error(w, "Admin endpoints are disabled, configure ADMIN_API_TOKEN to enable them", http.StatusForbidden)
+			return
+		}
+
+		if !hasBearerToken(r, wh.AdminApiToken) {
+			logRequest(r)
+			http.Error(w, "Invalid or missing admin token", http.StatusUnauthorized)
+			fmt.Println("WARN Rejecting admin request with invalid token")
+			return
+		}
+
+		handler(w, r)
+	}
+}
@@ -6,98 +6,95 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"strconv"
 	"sync"
 	"syscall"
 	"time"
 
-	"github.com/pkmollman/nagios-better-stack-connector/betterstack"
 	"github.com/pkmollman/nagios-better-stack-connector/database"
-	"github.com/pkmollman/nagios-better-stack-connector/database/sqlitedb"
+	"github.com/pkmollman/nagios-better-stack-connector/incidents"
 	"github.com/pkmollman/nagios-better-stack-connector/nagios"
+	"github.com/pkmollman/nagios-better-stack-connector/routing"
 )
 
 type webHandler struct {
-	dbClient                       database.DatabaseClient
-	betterClient                   *betterstack.BetterStackClient
-	nagiosClient                   *nagios.NagiosClient
-	BetterStackDefaultContactEmail string
-	healthStatus                   nbscStatus
-	healthStatusMutex              sync.Mutex
+	dbClient                 database.DatabaseClient
+	destinations             *incidents.DestinationRegistry
+	nagiosSites              *nagios.SiteRegistry
+	IncidentCommentInterval  time.Duration
+	NagiosDowntimePolicy     string
+	NagiosFlappingPolicy     string
+	NagiosPageStates         []string
+	NagiosPageSoftStates     bool
+	NagiosEscalationPolicy   string
+	NagiosDeescalationPolicy string
+	NagiosCorrelateServices  bool
+	NagiosCorrelateParents   bool
+	Router                   *routing.Router
+	IncidentTemplates        *routing.Templates
+	IncidentChannels         routing.StateChannels
+	RateLimiter              *rateLimiter
+	IncidentRecurrencePolicy string
+	IncidentRecurrenceWindow time.Duration
+	NagiosEventToken         string
+	AdminApiToken            string
+	healthStatus             nbscStatus
+	healthStatusMutex        sync.Mutex
 }
 
-func NewWebHandler(dbClient database.DatabaseClient, betterStackClient *betterstack.BetterStackClient, nagiosClient *nagios.NagiosClient) *webHandler {
+func NewWebHandler(dbClient database.DatabaseClient, destinations *incidents.DestinationRegistry, nagiosSites *nagios.SiteRegistry) *webHandler {
 	handler := webHandler{
-		dbClient:     dbClient,
-		betterClient: betterStackClient,
-		nagiosClient: nagiosClient,
+		dbClient:                 dbClient,
+		destinations:             destinations,
+		nagiosSites:              nagiosSites,
+		Router:                   &routing.Router{},
+		IncidentTemplates:        &routing.Templates{},
+		NagiosPageSoftStates:     true,
+		NagiosEscalationPolicy:   ESCALATION_POLICY_RECREATE,
+		NagiosDeescalationPolicy: ESCALATION_POLICY_COMMENT,
+		NagiosCorrelateServices:  true,
+		NagiosCorrelateParents:   true,
+		RateLimiter:              newRateLimiter(),
+		IncidentRecurrencePolicy: RECURRENCE_POLICY_NONE,
+		IncidentRecurrenceWindow: time.Hour,
 	}
 
-	handler.startHealthRoutine()
-
 	return &handler
 }
 
 func StartServer() {
-	// DB
-	sqliteDbPath := getEnvVarOrPanic("SQLITE_DB_PATH")
-	sqliteDbBackupDirPath := getEnvVarOrPanic("SQLITE_DB_BACKUP_DIR_PATH")
-	sqliteDbBackupFrequencyMinutesString := getEnvVarOrPanic("SQLITE_DB_BACKUP_FREQUENCY_MINUTES")
-
-	// convert string to int
-	sqliteDbBackupFrequencyMinutes, err := strconv.Atoi(sqliteDbBackupFrequencyMinutesString)
-	if err != nil {
-		fmt.Println("unable to convert SQLITE_DB_BACKUP_FREQUENCY_MINUTES to int:", err)
-		os.Exit(1)
-	}
-
-	// BetterStack
-	betterStackApiKey := getEnvVarOrPanic("BETTER_STACK_API_KEY")
-	betterDefaultContactEmail := getEnvVarOrPanic("BETTER_STACK_DEFAULT_CONTACT_EMAIL")
-
-	// Nagios
-	nagiosUser := getEnvVarOrPanic("NAGIOS_THRUK_API_USER")
-	nagiosKey := getEnvVarOrPanic("NAGIOS_THRUK_API_KEY")
-	nagiosBaseUrl := getEnvVarOrPanic("NAGIOS_THRUK_BASE_URL")
-	nagiosSiteName := getEnvVarOrPanic("NAGIOS_THRUK_SITE_NAME")
+	dbClient := loadDatabaseClient()
+	backupFrequency := loadBackupFrequency()
+	webHandler := loadWebHandler(dbClient)
 
-	// create database client
-	var dbClient database.DatabaseClient
-
-	// should be able to swap this out for anything that implements the database.DatabaseClient interface
-	dbClient, err = sqlitedb.NewSQLiteClient(sqliteDbPath, sqliteDbBackupDirPath)
+	lock, err := lockDatabase()
 	if err != nil {
-		fmt.Println("unable to create database client:", err.Error())
+		fmt.Println("unable to lock database:", err.Error())
 		os.Exit(1)
 	}
+	defer lock.Close()
 
-	err = dbClient.Init()
-	if err != nil {
-		fmt.Println("unable to initialize database client:", err.Error())
-		os.Exit(1)
-	}
+	initDatabaseClient(dbClient)
 
 	// start backup routine
 	go func() {
-		fmt.Println("Starting backup routine to backup every", sqliteDbBackupFrequencyMinutes, "minute(s)")
+		fmt.Println("Starting backup routine to backup every", backupFrequency)
 		for {
-			time.Sleep(time.Minute * time.Duration(sqliteDbBackupFrequencyMinutes))
+			time.Sleep(backupFrequency)
 			func() {
 				dbClient.Lock()
 				defer dbClient.Unlock()
-				dbClient.Backup()
+				_, err := dbClient.Backup()
+				if err != nil {
+					fmt.Println("ERROR Failed to backup database: " + err.Error())
+				}
 			}()
 		}
 	}()
 
-	// create betterstack client
-	betterStackClient := betterstack.NewBetterStackClient(betterStackApiKey, "https://uptime.betterstack.com")
-
-	// create nagios client
-	nagiosClient := nagios.NewNagiosClient(nagiosUser, nagiosKey, nagiosBaseUrl, nagiosSiteName)
-
-	webHandler := NewWebHandler(dbClient, betterStackClient, nagiosClient)
-	webHandler.BetterStackDefaultContactEmail = betterDefaultContactEmail
+	webHandler.startHealthRoutine()
+	webHandler.startStormRoutine()
+	webHandler.startPendingRoutine()
+	webHandler.startSuppressionRoutine()
 
 	// create HTTP router
 	mux := http.NewServeMux()
@@ -105,7 +102,10 @@ func StartServer() {
 	// Handle Incoming Nagios Notifications
 	mux.HandleFunc("POST /api/nagios-event", webHandler.handleIncomingNagiosNotification)
 
-	// Handle Incoming Better Stack Webhooks
+	// Handle Incoming incident provider webhooks, without a destination they are for the first default destination.
+	// /api/better-stack-event is kept for existing Better Stack webhooks, for the first Better Stack destination
+	mux.HandleFunc("POST /api/incident-event/{destination}", webHandler.handleIncomingIncidentWebhook)
+	mux.HandleFunc("POST /api/incident-event", webHandler.handleIncomingIncidentWebhook)
 	mux.HandleFunc("POST /api/better-stack-event", webHandler.handleIncomingBetterStackWebhook)
 
 	// Handle Health Check
@@ -114,6 +114,48 @@ func StartServer() {
 	// Handle get event items
 	mux.HandleFunc("GET /api/event-items", webHandler.handleGetEventItems)
 
+	// Handle get the incidents opened for an event item at its destinations
+	mux.HandleFunc("GET /api/event-items/{id}/destinations", webHandler.handleGetEventItemDestinations)
+
+	// Handle scheduling Nagios downtime for an event item
+	mux.HandleFunc("POST /api/event-items/{id}/downtime", webHandler.requireAdminToken(webHandler.handleScheduleEventItemDowntime))
+
+	// Handle get active downtimes
+	mux.HandleFunc("GET /api/downtimes", webHandler.handleGetDowntimeItems)
+
+	// Handle get flapping hosts/services
+	mux.HandleFunc("GET /api/flapping", webHandler.handleGetFlappingItems)
+
+	// Handle get routing rules, and testing which rule an event matches
+	mux.HandleFunc("GET /api/routing/rules", webHandler.handleGetRoutingRules)
+	mux.HandleFunc("POST /api/routing/match", webHandler.requireAdminToken(webHandler.handleRoutingMatch))
+
+	// Handle get problems attached to host incidents
+	mux.HandleFunc("GET /api/correlations", webHandler.handleGetCorrelationItems)
+
+	// Handle get problems grouped into one incident
+	mux.HandleFunc("GET /api/groups", webHandler.handleGetGroupMemberItems)
+
+	// Handle get the incident history of hosts/services
+	mux.HandleFunc("GET /api/recurrences", webHandler.handleGetRecurrenceItems)
+
+	// Handle get problems waiting out their grace period
+	mux.HandleFunc("GET /api/pending", webHandler.handleGetPendingItems)
+
+	// Handle managing suppression rules
+	mux.HandleFunc("GET /api/suppressions", webHandler.handleGetSuppressionItems)
+	mux.HandleFunc("POST /api/suppressions", webHandler.requireAdminToken(webHandler.handleCreateSuppressionItem))
+	mux.HandleFunc("GET /api/suppressions/{id}", webHandler.handleGetSuppressionItem)
+	mux.HandleFunc("PUT /api/suppressions/{id}", webHandler.requireAdminToken(webHandler.handleUpdateSuppressionItem))
+	mux.HandleFunc("DELETE /api/suppressions/{id}", webHandler.requireAdminToken(webHandler.handleDeleteSuppressionItem))
+
+	// Handle get rate limits, and metrics
+	mux.HandleFunc("GET /api/rate-limits", webHandler.handleGetRateLimits)
+	mux.HandleFunc("GET /api/metrics", webHandler.handleGetMetrics)
+
+	// Handle get recorded decisions
+	mux.HandleFunc("GET /api/decisions", webHandler.handleGetDecisionItems)
+
 	// HTTP server
 	httpServer := &http.Server{
 		Addr:    ":8080",
@@ -146,7 +188,10 @@ func StartServer() {
 
 	// Wait for exclusive access to the database to backups and shutdown
 	dbClient.Lock()
-	dbClient.Backup()
+	_, err = dbClient.Backup()
+	if err != nil {
+		fmt.Println("Error backing up database:", err.Error())
+	}
 	err = dbClient.Shutdown()
 	if err != nil {
 		fmt.Println(err)